@@ -0,0 +1,48 @@
+package main
+
+// The bit cursor counts from the left of the pattern, so BitPos 0 is bit 7.
+func (zbuf *ZerzBuffer) Bit() uint {
+	return 7 - zbuf.BitPos
+}
+
+// Moves the bit cursor n bits right, on into the following bytes.
+func (zbuf *ZerzBuffer) ForwardBit(n int64) {
+	n = clampCount(n, zbuf.File.Size*8)
+	pos := int64(zbuf.BitPos) + n
+	if zbuf.Offset+pos/8 >= zbuf.File.Size {
+		zbuf.Offset, zbuf.BitPos = zbuf.File.Size-1, 7
+		return
+	}
+	zbuf.Offset += pos / 8
+	zbuf.BitPos = uint(pos % 8)
+}
+
+// Moves the bit cursor n bits left, back into the preceding bytes.
+func (zbuf *ZerzBuffer) BackwardBit(n int64) {
+	n = clampCount(n, zbuf.File.Size*8)
+	pos := int64(zbuf.BitPos) - n
+	back := (7 - pos) / 8
+	if back > zbuf.Offset {
+		zbuf.Offset, zbuf.BitPos = 0, 0
+		return
+	}
+	zbuf.Offset -= back
+	zbuf.BitPos = uint(pos + back*8)
+}
+
+func (zbuf *ZerzBuffer) ToggleBit() {
+	b := zbuf.File.Get(zbuf.Offset)
+	zbuf.WriteBytes(zbuf.Offset, []byte{b ^ 1<<zbuf.Bit()})
+}
+
+func (zbuf *ZerzBuffer) SetBit(on bool) {
+	b := zbuf.File.Get(zbuf.Offset)
+	if on {
+		b |= 1 << zbuf.Bit()
+	} else {
+		b &^= 1 << zbuf.Bit()
+	}
+	if b != zbuf.File.Get(zbuf.Offset) {
+		zbuf.WriteBytes(zbuf.Offset, []byte{b})
+	}
+}
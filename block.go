@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Asks where a block of length bytes should go, and checks it fits.
+func (zbuf *ZerzBuffer) blockDest(zed *ZerzEditor, tabbarscroll int, prompt string,
+	length int64) (int64, bool) {
+	value := zed.Prompt(prompt, tabbarscroll)
+	if value == "" {
+		return 0, false
+	}
+	dest, err := strconv.ParseInt(value, 0, 64)
+	if err != nil {
+		zed.Msg("Bad offset %q: %s", value, numErr(err))
+		return 0, false
+	} else if dest < 0 || dest > zbuf.File.Size-length {
+		zed.Msg("Block at 0x%x would run past end of file", dest)
+		return 0, false
+	}
+	return dest, true
+}
+
+// Copies a range to somewhere else in the file, like memmove, so it's fine
+// for the two to overlap.
+func (zbuf *ZerzBuffer) MoveBlock(zed *ZerzEditor, tabbarscroll int) {
+	start, length, ok := zbuf.GetRange(zed, tabbarscroll)
+	if !ok {
+		return
+	}
+	dest, ok := zbuf.blockDest(zed, tabbarscroll, fmt.Sprintf("move %d bytes from 0x%x to",
+		length, start), length)
+	if !ok || dest == start {
+		return
+	}
+	data := zbuf.File.Read(start, length)
+	zbuf.Preview = []ZerzSpan{{dest, data}, {start, data}}
+	move := zed.YesNo(fmt.Sprintf("Move 0x%x-0x%x to 0x%x?", start, start+length-1, dest),
+		tabbarscroll)
+	zbuf.Preview = nil
+	if !move {
+		return
+	}
+	zbuf.WriteBytes(dest, data)
+	zbuf.MarkActive = false
+	zbuf.Offset = dest
+	zed.Msg("Moved %d bytes to 0x%x", length, dest)
+}
+
+// Swaps a range with another of the same length.
+func (zbuf *ZerzBuffer) SwapBlocks(zed *ZerzEditor, tabbarscroll int) {
+	start, length, ok := zbuf.GetRange(zed, tabbarscroll)
+	if !ok {
+		return
+	}
+	other, ok := zbuf.blockDest(zed, tabbarscroll, fmt.Sprintf("swap %d bytes at 0x%x with",
+		length, start), length)
+	if !ok || other == start {
+		return
+	} else if start < other+length && other < start+length {
+		zed.Msg("Can't swap overlapping blocks")
+		return
+	}
+	a := zbuf.File.Read(start, length)
+	b := zbuf.File.Read(other, length)
+	zbuf.Preview = []ZerzSpan{{start, b}, {other, a}}
+	swap := zed.YesNo(fmt.Sprintf("Swap 0x%x-0x%x with 0x%x-0x%x?", start, start+length-1,
+		other, other+length-1), tabbarscroll)
+	zbuf.Preview = nil
+	if !swap {
+		return
+	}
+	zbuf.Grouped(func() {
+		zbuf.WriteBytes(start, b)
+		zbuf.WriteBytes(other, a)
+	})
+	zbuf.MarkActive = false
+	zed.Msg("Swapped %d bytes at 0x%x and 0x%x", length, start, other)
+}
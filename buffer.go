@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"math"
+	"os"
 	"strconv"
 
 	termutil "github.com/japanoise/termbox-util"
@@ -19,6 +21,10 @@ const (
 	ModeChar
 )
 
+// The most a single edit will insert or build; anything bigger is probably a
+// typo, and would have to fit in memory anyway.
+const ZInsertMax = 1 << 26
+
 const (
 	Int8 ZerzIntWidth = iota
 	Int16
@@ -26,14 +32,43 @@ const (
 	Int64
 )
 
+// Bytes to show at Offset in place of what's in the file, so a change can be
+// looked at before it's made.
+type ZerzSpan struct {
+	Offset int64
+	Data   []byte
+}
+
 type ZerzBuffer struct {
-	File      *ZerzFile
-	Offset    int64
-	Scroll    int64
-	Mode      ZerzMode
-	IntWidth  ZerzIntWidth
-	BigEndian bool
-	Focused   bool
+	File       *ZerzFile
+	Offset     int64
+	Scroll     int64
+	Mode       ZerzMode
+	IntWidth   ZerzIntWidth
+	BigEndian  bool
+	Focused    bool
+	Insert     bool
+	Typing     bool
+	TextColumn bool
+	Nibble     bool
+	NibbleAt   int64
+	Mark       int64
+	MarkActive bool
+	Preview    []ZerzSpan
+	Highlight  ZerzMatcher
+	highlights []ZerzSpan
+	BitPos     uint
+	StrEnc     ZerzStrEnc
+	StrTerm    ZerzStrTerm
+	StrPad     int64
+	UndoList   []*ZerzUndo
+	RedoList   []*ZerzUndo
+	SavedUndo  int
+	Recovered  []*ZerzUndo
+
+	journalFile   *os.File
+	journalFailed bool
+	journalErr    error
 }
 
 func CreateBuffer(filename string) (*ZerzBuffer, error) {
@@ -48,6 +83,58 @@ func (zbuf *ZerzBuffer) DestroyBuffer() {
 	zbuf.File.Close()
 }
 
+func (zbuf *ZerzBuffer) Save() error {
+	if err := zbuf.File.Save(); err != nil {
+		return err
+	}
+	zbuf.SavedUndo = len(zbuf.UndoList)
+	zbuf.DiscardJournal()
+	return nil
+}
+
+// Throws away every pending change; the undo history goes with it since it no
+// longer describes what's in the file.
+func (zbuf *ZerzBuffer) Revert() {
+	zbuf.File.Revert()
+	zbuf.UndoList = nil
+	zbuf.RedoList = nil
+	zbuf.SavedUndo = 0
+	zbuf.DiscardJournal()
+	zbuf.clampOffset()
+}
+
+func (zbuf *ZerzBuffer) ModStr() string {
+	if zbuf.Modified() {
+		return "**"
+	}
+	return "--"
+}
+
+func (zbuf *ZerzBuffer) InsStr() string {
+	ret := "OVR"
+	if zbuf.Insert {
+		ret = "INS"
+	}
+	if zbuf.Typing && zbuf.TextColumn {
+		ret += " typing text"
+	} else if zbuf.Typing {
+		ret += " typing hex"
+	}
+	return ret
+}
+
+func (zbuf *ZerzBuffer) clampOffset() {
+	if zbuf.Offset >= zbuf.File.Size {
+		zbuf.Offset = zbuf.File.Size - 1
+	}
+	if zbuf.Mark >= zbuf.File.Size {
+		zbuf.Mark = zbuf.File.Size - 1
+	}
+	if zbuf.Scroll > zbuf.Offset {
+		zbuf.Scroll = zbuf.Offset & (math.MaxInt64 - 0x0F)
+	}
+}
+
 func IsPrintableAscii(c byte) bool {
 	return 0x20 <= c && c <= 0x7e
 }
@@ -74,6 +161,15 @@ func (zbuf *ZerzBuffer) drawByte(offset, j int64, x1, y, k, nearOffset, farOffse
 	} else if offset == zbuf.Offset {
 		bg = zbuf.CursColor()
 		fg = ZCursorFg
+	} else if zbuf.InPreview(offset) {
+		bg = ZPreviewBg
+		fg = ZPreviewFg
+	} else if zbuf.InHighlight(offset) {
+		bg = ZMatchBg
+		fg = ZMatchFg
+	} else if zbuf.InRegion(offset) {
+		bg = ZRegionBg
+		fg = ZRegionFg
 	} else if offset > zbuf.Offset && (zbuf.Mode == ModeInt || zbuf.Mode == ModeUInt) {
 		if zbuf.IntWidth > Int8 && offset == zbuf.Offset+1 {
 			bg = zbuf.CursColor()
@@ -86,19 +182,60 @@ func (zbuf *ZerzBuffer) drawByte(offset, j int64, x1, y, k, nearOffset, farOffse
 			fg = ZCursorFg
 		}
 	}
-	termutil.PrintStringFgBg(x1+nearOffset+int(10+j)+(3*k), y,
-		fmt.Sprintf("%02x", zbuf.File.Bytes[offset]),
-		fg, bg)
-	if IsPrintableAscii(zbuf.File.Bytes[offset]) {
-		termbox.SetCell(x1+farOffset+(2*k), y, rune(zbuf.File.Bytes[offset]),
-			fg, bg)
-	} else if zbuf.File.Bytes[offset] < 0x20 {
-		termbox.SetCell(x1+farOffset+(2*k), y, rune(zbuf.File.Bytes[offset]|0x40),
-			fg|termbox.AttrReverse, bg)
+	hexfg, hexbg, chfg, chbg := fg, bg, fg, bg
+	typing := zbuf.Focused && zbuf.Typing && offset == zbuf.Offset
+	if typing {
+		// Only the column being typed into gets a solid cursor
+		if zbuf.TextColumn {
+			hexfg, hexbg = zbuf.CursColor(), ZBgColor
+		} else {
+			chfg, chbg = zbuf.CursColor(), ZBgColor
+		}
+	}
+
+	b := zbuf.byteAt(offset)
+	hexx := x1 + nearOffset + int(10+j) + (3 * k)
+	if typing && !zbuf.TextColumn {
+		// Underline the nybble the next keypress will go to
+		hex := fmt.Sprintf("%02x", b)
+		hifg, lofg := hexfg|termbox.AttrUnderline|termbox.AttrBold, hexfg
+		if zbuf.lowNibble() {
+			hifg, lofg = lofg, hifg
+		}
+		termbox.SetCell(hexx, y, rune(hex[0]), hifg, hexbg)
+		termbox.SetCell(hexx+1, y, rune(hex[1]), lofg, hexbg)
+	} else {
+		termutil.PrintStringFgBg(hexx, y, fmt.Sprintf("%02x", b), hexfg, hexbg)
+	}
+	if IsPrintableAscii(b) {
+		termbox.SetCell(x1+farOffset+(2*k), y, rune(b),
+			chfg, chbg)
+	} else if b < 0x20 {
+		termbox.SetCell(x1+farOffset+(2*k), y, rune(b|0x40),
+			chfg|termbox.AttrReverse, chbg)
 	} else {
 		termbox.SetCell(x1+farOffset+(2*k), y, '.',
-			ZFgUPColor, bg)
+			ZFgUPColor, chbg)
+	}
+}
+
+func (zbuf *ZerzBuffer) InPreview(offset int64) bool {
+	for _, span := range zbuf.Preview {
+		if span.Offset <= offset && offset < span.Offset+int64(len(span.Data)) {
+			return true
+		}
+	}
+	return false
+}
+
+// The byte to display at offset, taking any preview into account.
+func (zbuf *ZerzBuffer) byteAt(offset int64) byte {
+	for _, span := range zbuf.Preview {
+		if span.Offset <= offset && offset < span.Offset+int64(len(span.Data)) {
+			return span.Data[offset-span.Offset]
+		}
 	}
+	return zbuf.File.Get(offset)
 }
 
 func (zbuf *ZerzBuffer) DrawBuffer(x1, y1, x2, y2 int) {
@@ -107,6 +244,7 @@ func (zbuf *ZerzBuffer) DrawBuffer(x1, y1, x2, y2 int) {
 	if boty > zbuf.File.Size {
 		boty = (zbuf.File.Size - 1) & (math.MaxInt64 - 0x0F)
 	}
+	zbuf.findHighlights(zbuf.Scroll, boty+0x10)
 	y := y1
 	for i := zbuf.Scroll; i <= boty; i += 0x10 {
 		termutil.PrintStringFgBg(x1, y, fmt.Sprintf("%08x:", i),
@@ -136,60 +274,56 @@ func (zbuf *ZerzBuffer) EndStr() string {
 	return "lilend"
 }
 
-func (zbuf *ZerzBuffer) ForwardByte() {
-	if zbuf.Offset < zbuf.File.Size-1 {
-		zbuf.Offset++
+// Moves the cursor n bytes forward (or back, if n is negative), stopping at
+// either end of the file.
+func (zbuf *ZerzBuffer) moveBy(n int64) {
+	if n > 0 && n > zbuf.File.Size-1-zbuf.Offset {
+		zbuf.Offset = zbuf.File.Size - 1
+	} else if n < 0 && -n > zbuf.Offset {
+		zbuf.Offset = 0
+	} else {
+		zbuf.Offset += n
 	}
 }
 
-func (zbuf *ZerzBuffer) BackwardByte() {
-	if zbuf.Offset > 0 {
-		zbuf.Offset--
+func clampCount(n, max int64) int64 {
+	if n > max {
+		return max
 	}
+	return n
 }
 
-func (zbuf *ZerzBuffer) ForwardWord() {
-	if zbuf.Offset < zbuf.File.Size-2 {
-		zbuf.Offset += 2
-	} else {
-		zbuf.Offset = zbuf.File.Size - 1
-	}
+func (zbuf *ZerzBuffer) ForwardByte(n int64) {
+	zbuf.moveBy(clampCount(n, zbuf.File.Size))
 }
 
-func (zbuf *ZerzBuffer) BackwardWord() {
-	if zbuf.Offset > 1 {
-		zbuf.Offset -= 2
-	} else {
-		zbuf.Offset = 0
-	}
+func (zbuf *ZerzBuffer) BackwardByte(n int64) {
+	zbuf.moveBy(-clampCount(n, zbuf.File.Size))
 }
 
-func (zbuf *ZerzBuffer) ForwardDWord() {
-	if zbuf.Offset < zbuf.File.Size-4 {
-		zbuf.Offset += 4
-	} else {
-		zbuf.Offset = zbuf.File.Size - 1
-	}
+func (zbuf *ZerzBuffer) ForwardWord(n int64) {
+	zbuf.moveBy(2 * clampCount(n, zbuf.File.Size))
 }
 
-func (zbuf *ZerzBuffer) BackwardDWord() {
-	if zbuf.Offset > 3 {
-		zbuf.Offset -= 4
-	} else {
-		zbuf.Offset = 0
-	}
+func (zbuf *ZerzBuffer) BackwardWord(n int64) {
+	zbuf.moveBy(-2 * clampCount(n, zbuf.File.Size))
 }
 
-func (zbuf *ZerzBuffer) ForwardParagraph() {
-	if zbuf.Offset < zbuf.File.Size-0x10 {
-		zbuf.Offset += 0x10
-	}
+func (zbuf *ZerzBuffer) ForwardDWord(n int64) {
+	zbuf.moveBy(4 * clampCount(n, zbuf.File.Size))
 }
 
-func (zbuf *ZerzBuffer) BackwardParagraph() {
-	if zbuf.Offset >= 0x10 {
-		zbuf.Offset -= 0x10
-	}
+func (zbuf *ZerzBuffer) BackwardDWord(n int64) {
+	zbuf.moveBy(-4 * clampCount(n, zbuf.File.Size))
+}
+
+// Paragraph motions only move by whole lines, so they stop short of the ends.
+func (zbuf *ZerzBuffer) ForwardParagraph(n int64) {
+	zbuf.Offset += clampCount(n, (zbuf.File.Size-1-zbuf.Offset)/0x10) * 0x10
+}
+
+func (zbuf *ZerzBuffer) BackwardParagraph(n int64) {
+	zbuf.Offset -= clampCount(n, zbuf.Offset/0x10) * 0x10
 }
 
 func (zbuf *ZerzBuffer) StartOfFile() {
@@ -253,23 +387,72 @@ func (zbuf *ZerzBuffer) ScrollDown() {
 	}
 }
 
-func (zbuf *ZerzBuffer) Click(x1, y1, mousex, mousey int) {
+func (zbuf *ZerzBuffer) SetMark() {
+	zbuf.Mark = zbuf.Offset
+	zbuf.MarkActive = true
+}
+
+func (zbuf *ZerzBuffer) ExchangePointAndMark() {
+	zbuf.Offset, zbuf.Mark = zbuf.Mark, zbuf.Offset
+	zbuf.MarkActive = true
+}
+
+// Returns the start and length of the region between the mark and the cursor,
+// both ends included.
+func (zbuf *ZerzBuffer) Region() (int64, int64, bool) {
+	if !zbuf.MarkActive {
+		return 0, 0, false
+	}
+	if zbuf.Mark < zbuf.Offset {
+		return zbuf.Mark, zbuf.Offset - zbuf.Mark + 1, true
+	}
+	return zbuf.Offset, zbuf.Mark - zbuf.Offset + 1, true
+}
+
+func (zbuf *ZerzBuffer) InRegion(offset int64) bool {
+	start, length, ok := zbuf.Region()
+	return ok && start <= offset && offset < start+length
+}
+
+func (zbuf *ZerzBuffer) RegionStr() string {
+	start, length, ok := zbuf.Region()
+	if !ok {
+		return ""
+	}
+	end := start + length - 1
+	return fmt.Sprintf(" | Region: %x-%x (%d-%d) len %x (%d)",
+		start, end, start, end, length, length)
+}
+
+// A plain click moves the cursor and drops the mark there; dragging then
+// activates the region between the two.
+func (zbuf *ZerzBuffer) Click(x1, y1, mousex, mousey int, drag bool) {
 	offsetx, offsety := mousex-x1, mousey-y1
 	zbuf.Offset = zbuf.Scroll + int64(offsety)*0x10
-	if 52 <= offsetx && offsetx <= 67 {
-		zbuf.Offset += int64(offsetx - 52)
+	if 51 <= offsetx && offsetx <= 66 {
+		zbuf.Offset += int64(offsetx - 51)
+		zbuf.SetTextColumn(true)
 	} else if offsetx > 66 {
 		zbuf.Offset += 0x0f
+		zbuf.SetTextColumn(true)
 	} else if 10 <= offsetx && offsetx < 50 {
 		// offset x into hex
 		oxix := int64(offsetx - 10)
 		zbuf.Offset += oxix * 2 / 5
+		zbuf.TextColumn = false
 	} else if 50 <= offsetx {
 		zbuf.Offset += 0x0f
+		zbuf.TextColumn = false
 	}
 	if zbuf.Offset >= zbuf.File.Size {
 		zbuf.Offset = zbuf.File.Size - 1
 	}
+	if drag {
+		zbuf.MarkActive = true
+	} else {
+		zbuf.Mark = zbuf.Offset
+		zbuf.MarkActive = false
+	}
 }
 
 func (zbuf *ZerzBuffer) DoScroll(y1, y2 int) {
@@ -301,67 +484,78 @@ func (zbuf *ZerzBuffer) GetCursorData() string {
 	case ModeInt:
 		switch zbuf.IntWidth {
 		case Int8:
-			return fmt.Sprintf("int8: %d", int8(zbuf.File.Bytes[zbuf.Offset]))
+			return fmt.Sprintf("int8: %d", int8(zbuf.File.Get(zbuf.Offset)))
 		case Int16:
 			if zbuf.Offset+1 < zbuf.File.Size {
 				return fmt.Sprintf("int16: %d", int16(zbuf.interpretBytesAsInteger(
-					zbuf.File.Bytes[zbuf.Offset:zbuf.Offset+2])))
+					zbuf.File.Read(zbuf.Offset, 2))))
 			}
 		case Int32:
 			if zbuf.Offset+3 < zbuf.File.Size {
 				return fmt.Sprintf("int32: %d", int32(zbuf.interpretBytesAsInteger(
-					zbuf.File.Bytes[zbuf.Offset:zbuf.Offset+4])))
+					zbuf.File.Read(zbuf.Offset, 4))))
 			}
 		case Int64:
 			if zbuf.Offset+7 < zbuf.File.Size {
 				return fmt.Sprintf("int64: %d", int64(zbuf.interpretBytesAsInteger(
-					zbuf.File.Bytes[zbuf.Offset:zbuf.Offset+8])))
+					zbuf.File.Read(zbuf.Offset, 8))))
 			}
 		}
 	case ModeUInt:
 		switch zbuf.IntWidth {
 		case Int8:
-			return fmt.Sprintf("uint8: %d", uint8(zbuf.File.Bytes[zbuf.Offset]))
+			return fmt.Sprintf("uint8: %d", uint8(zbuf.File.Get(zbuf.Offset)))
 		case Int16:
 			if zbuf.Offset+1 < zbuf.File.Size {
 				return fmt.Sprintf("uint16: %d", uint16(zbuf.interpretBytesAsInteger(
-					zbuf.File.Bytes[zbuf.Offset:zbuf.Offset+2])))
+					zbuf.File.Read(zbuf.Offset, 2))))
 			}
 		case Int32:
 			if zbuf.Offset+3 < zbuf.File.Size {
 				return fmt.Sprintf("uint32: %d", uint32(zbuf.interpretBytesAsInteger(
-					zbuf.File.Bytes[zbuf.Offset:zbuf.Offset+4])))
+					zbuf.File.Read(zbuf.Offset, 4))))
 			}
 		case Int64:
 			if zbuf.Offset+7 < zbuf.File.Size {
 				return fmt.Sprintf("uint64: %d", uint64(zbuf.interpretBytesAsInteger(
-					zbuf.File.Bytes[zbuf.Offset:zbuf.Offset+8])))
+					zbuf.File.Read(zbuf.Offset, 8))))
 			}
 		}
 	case ModePattern:
-		return fmt.Sprintf("pattern: %08b", zbuf.File.Bytes[zbuf.Offset])
+		return fmt.Sprintf("pattern: %08b bit %d", zbuf.File.Get(zbuf.Offset), zbuf.Bit())
 	case ModeChar:
-		return fmt.Sprintf("char: %c", zbuf.File.Bytes[zbuf.Offset])
+		return fmt.Sprintf("char: %c (%s)", zbuf.File.Get(zbuf.Offset), zbuf.StrOptStr())
 	}
 	return "???"
 }
 
-func (zbuf *ZerzBuffer) Edit(zed *ZerzEditor, tabbarscroll int) {
-	value := termutil.Prompt("value", func(sx, sy int) {
-		zed.Draw(tabbarscroll, sx, sy, 0, 2, sx, sy-2)
-	})
-	termbox.HideCursor()
-	if value == "" {
-		return
+func (zbuf *ZerzBuffer) encodeInteger(integer uint64) []byte {
+	data := make([]byte, 1<<uint(zbuf.IntWidth))
+	if zbuf.BigEndian {
+		for i := len(data) - 1; i >= 0; i-- {
+			data[i] = byte(integer & 0xFF)
+			integer >>= 8
+		}
+	} else {
+		for i := 0; i < len(data); i++ {
+			data[i] = byte(integer & 0xFF)
+			integer >>= 8
+		}
 	}
+	return data
+}
+
+// Encodes a value typed in for the current mode, width and endianness.
+func (zbuf *ZerzBuffer) EncodeValue(value string) ([]byte, error) {
 	switch zbuf.Mode {
 	case ModePattern:
 		result, err := strconv.ParseUint(value, 16, 8)
-		if err == nil {
-			zbuf.File.Bytes[zbuf.Offset] = byte(result)
+		if err != nil {
+			return nil, err
 		}
+		return []byte{byte(result)}, nil
 	case ModeChar:
-		zbuf.File.Bytes[zbuf.Offset] = value[0]
+		return zbuf.EncodeString(value)
 	case ModeUInt:
 		var result uint64
 		var err error
@@ -376,28 +570,10 @@ func (zbuf *ZerzBuffer) Edit(zed *ZerzEditor, tabbarscroll int) {
 			result, err = strconv.ParseUint(value, 0, 64)
 		}
 		if err != nil {
-			return
+			return nil, err
 		}
-		if zbuf.BigEndian {
-			for i := int64(1<<uint(zbuf.IntWidth)) - 1; i >= 0; i-- {
-				roffset := zbuf.Offset + i
-				if roffset >= zbuf.File.Size {
-					break
-				}
-				zbuf.File.Bytes[roffset] = byte(result & 0xFF)
-				result >>= 8
-			}
-		} else {
-			for i := int64(0); i < int64(1<<uint(zbuf.IntWidth)); i++ {
-				roffset := zbuf.Offset + i
-				if roffset >= zbuf.File.Size {
-					break
-				}
-				zbuf.File.Bytes[roffset] = byte(result & 0xFF)
-				result >>= 8
-			}
-		}
-	case ModeInt:
+		return zbuf.encodeInteger(result), nil
+	default:
 		var result int64
 		var err error
 		switch zbuf.IntWidth {
@@ -411,47 +587,161 @@ func (zbuf *ZerzBuffer) Edit(zed *ZerzEditor, tabbarscroll int) {
 			result, err = strconv.ParseInt(value, 0, 64)
 		}
 		if err != nil {
-			return
-		}
-		if zbuf.BigEndian {
-			for i := int64(1<<uint(zbuf.IntWidth)) - 1; i >= 0; i-- {
-				roffset := zbuf.Offset + i
-				if roffset >= zbuf.File.Size {
-					break
-				}
-				zbuf.File.Bytes[roffset] = byte(result & 0xFF)
-				result >>= 8
-			}
-		} else {
-			for i := int64(0); i < int64(1<<uint(zbuf.IntWidth)); i++ {
-				roffset := zbuf.Offset + i
-				if roffset >= zbuf.File.Size {
-					break
-				}
-				zbuf.File.Bytes[roffset] = byte(result & 0xFF)
-				result >>= 8
-			}
+			return nil, err
 		}
+		return zbuf.encodeInteger(uint64(result)), nil
+	}
+}
+
+// Writes the value typed in at the cursor, n times over.
+func (zbuf *ZerzBuffer) Edit(zed *ZerzEditor, tabbarscroll int, n int64) {
+	value := zed.Prompt("value", tabbarscroll)
+	if value == "" {
+		return
+	}
+	data, err := zbuf.EncodeValue(value)
+	if err != nil {
+		zed.Msg("Bad value %q: %s", value, numErr(err))
+		return
+	}
+	if !zbuf.Insert {
+		// No point building more than will fit
+		n = clampCount(n, (zbuf.File.Size-zbuf.Offset)/int64(len(data))+1)
+	} else if n > ZInsertMax/int64(len(data)) {
+		zed.Msg("Won't insert more than %d bytes at once", ZInsertMax)
+		return
+	}
+	data = bytes.Repeat(data, int(n))
+	if wrote := zbuf.PutBytes(data); zbuf.Insert {
+		zed.Msg("Inserted %d bytes", wrote)
+	} else if wrote < len(data) {
+		zed.Msg("Wrote %d of %d bytes; hit end of file", wrote, len(data))
+	} else {
+		zed.Msg("Wrote %d bytes", wrote)
+	}
+}
+
+// Writes a run of bytes typed in any notation ParseByteNotation knows at the
+// cursor. Anything past the end of the file extends it.
+func (zbuf *ZerzBuffer) WriteNotation(zed *ZerzEditor, tabbarscroll int) {
+	value := zed.Prompt("bytes (hex, {0x12, ...}, \\x12..., base64)", tabbarscroll)
+	if value == "" {
+		return
+	}
+	data, kind, err := ParseByteNotation(value)
+	if err != nil {
+		zed.Msg("Bad bytes: %s", err)
+		return
+	}
+	question := fmt.Sprintf("Write %d bytes (%s)", len(data), kind)
+	if zbuf.Insert {
+		question = fmt.Sprintf("Insert %d bytes (%s)", len(data), kind)
+	} else if over := zbuf.Offset + int64(len(data)) - zbuf.File.Size; over > 0 {
+		question += fmt.Sprintf(", extending the file by %d", over)
+	}
+	if !zbuf.Insert {
+		zbuf.Preview = []ZerzSpan{{zbuf.Offset, data}}
+	}
+	ok := zed.YesNo(question+"?", tabbarscroll)
+	zbuf.Preview = nil
+	if !ok {
+		return
+	}
+	if zbuf.Insert {
+		zbuf.InsertBytes(zbuf.Offset, data)
+	} else {
+		zbuf.ReplaceBytes(zbuf.Offset, int64(len(data)), data)
+	}
+	if zbuf.Insert {
+		zed.Msg("Inserted %d bytes (%s)", len(data), kind)
+	} else {
+		zed.Msg("Wrote %d bytes (%s)", len(data), kind)
 	}
 }
 
 func (zbuf *ZerzBuffer) GoTo(zed *ZerzEditor, tabbarscroll int) {
-	value := termutil.Prompt("value", func(sx, sy int) {
-		zed.Draw(tabbarscroll, sx, sy, 0, 2, sx, sy-2)
-	})
-	termbox.HideCursor()
+	value := zed.Prompt("value", tabbarscroll)
 	if value == "" {
 		return
 	}
 
 	result, err := strconv.ParseInt(value, 0, 64)
-	if err != nil || result < 0 {
+	if err != nil {
+		zed.Msg("Bad offset %q: %s", value, numErr(err))
+		return
+	} else if result < 0 {
+		zed.Msg("Bad offset %q: negative", value)
 		return
 	}
 
 	zbuf.Offset = result
 	if zbuf.Offset >= zbuf.File.Size {
 		zbuf.Offset = zbuf.File.Size - 1
+		zed.Msg("0x%x is past end of file; went to last byte", result)
 	}
 	zbuf.Scroll = zbuf.Offset
 }
+
+func (zbuf *ZerzBuffer) ToggleTyping() {
+	zbuf.Typing = !zbuf.Typing
+	zbuf.Nibble = false
+}
+
+// Moving into the text column starts typing, since there's nothing else to do
+// there.
+func (zbuf *ZerzBuffer) SetTextColumn(text bool) {
+	zbuf.TextColumn = text
+	zbuf.Nibble = false
+	if text {
+		zbuf.Typing = true
+	}
+}
+
+func (zbuf *ZerzBuffer) SwitchColumn() {
+	zbuf.SetTextColumn(!zbuf.TextColumn)
+	zbuf.Typing = true
+}
+
+// The low nybble is next only if the high one was just typed at this offset;
+// moving the cursor anywhere starts over on a high nybble.
+func (zbuf *ZerzBuffer) lowNibble() bool {
+	return zbuf.Nibble && zbuf.NibbleAt == zbuf.Offset
+}
+
+// Types one hex digit into the byte under the cursor, high nybble first, then
+// moves on to the next byte.
+func (zbuf *ZerzBuffer) TypeHex(ch rune) bool {
+	value, err := strconv.ParseUint(string(ch), 16, 8)
+	if err != nil {
+		return false
+	}
+	if zbuf.lowNibble() {
+		before := zbuf.Offset
+		b := zbuf.File.Get(zbuf.Offset)
+		zbuf.WriteBytes(zbuf.Offset, []byte{b&0xF0 | byte(value)})
+		zbuf.Nibble = false
+		zbuf.ForwardByte(1)
+		return zbuf.Offset != before
+	}
+	if zbuf.Insert {
+		zbuf.InsertBytes(zbuf.Offset, []byte{byte(value) << 4})
+	} else {
+		b := zbuf.File.Get(zbuf.Offset)
+		zbuf.WriteBytes(zbuf.Offset, []byte{b&0x0F | byte(value)<<4})
+	}
+	zbuf.Nibble = true
+	zbuf.NibbleAt = zbuf.Offset
+	return true
+}
+
+// Types a character into the text column, overwriting (or inserting) its
+// UTF-8 bytes and moving past them. Returns false once there's nowhere left
+// to type.
+func (zbuf *ZerzBuffer) TypeText(ch rune) bool {
+	before := zbuf.Offset
+	n := zbuf.PutBytes([]byte(string(ch)))
+	for i := 0; i < n; i++ {
+		zbuf.ForwardByte(1)
+	}
+	return n > 0 && zbuf.Offset != before
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// Most terminals won't take a clipboard much bigger than this over OSC 52.
+const ZOSC52Max = 100000
+
+var copyFormats = []string{"go", "c", "python", "hex", "base64"}
+
+// Writes data as a comma separated list of 0x12 bytes, on one line if it's
+// short or twelve to a line after indent if not.
+func byteList(data []byte, indent string) string {
+	var sb strings.Builder
+	if len(data) <= 16 {
+		for i, b := range data {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "0x%02x", b)
+		}
+		return sb.String()
+	}
+	sb.WriteString("\n")
+	for i := 0; i < len(data); i += 12 {
+		sb.WriteString(indent)
+		for j := i; j < len(data) && j < i+12; j++ {
+			if j > i {
+				sb.WriteString(" ")
+			}
+			fmt.Fprintf(&sb, "0x%02x,", data[j])
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func pythonBytes(data []byte) string {
+	var sb strings.Builder
+	sb.WriteString("b\"")
+	for _, b := range data {
+		switch {
+		case b == '\\' || b == '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(b)
+		case b == '\n':
+			sb.WriteString("\\n")
+		case b == '\r':
+			sb.WriteString("\\r")
+		case b == '\t':
+			sb.WriteString("\\t")
+		case 0x20 <= b && b < 0x7f:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "\\x%02x", b)
+		}
+	}
+	sb.WriteString("\"")
+	return sb.String()
+}
+
+// Writes data out as source code or text in one of copyFormats.
+func FormatBytes(data []byte, format string) (string, error) {
+	switch format {
+	case "go":
+		return "[]byte{" + byteList(data, "\t") + "}", nil
+	case "c":
+		return fmt.Sprintf("unsigned char data[%d] = {%s};", len(data), byteList(data, "    ")), nil
+	case "python", "py":
+		return pythonBytes(data), nil
+	case "hex":
+		return hex.EncodeToString(data), nil
+	case "base64", "b64":
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return "", errors.New("unknown format " + format)
+}
+
+// Puts text on the terminal's clipboard with OSC 52, getting it through tmux
+// if need be.
+func osc52(text string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		seq = "\x1bPtmux;\x1b" + seq + "\x1b\\"
+	}
+	_, err = tty.WriteString(seq)
+	return err
+}
+
+// Copies a range out as source code, to the clipboard or to a file.
+func (zbuf *ZerzBuffer) CopyAs(zed *ZerzEditor, tabbarscroll int) {
+	start, length, ok := zbuf.GetRange(zed, tabbarscroll)
+	if !ok {
+		return
+	}
+	fields := strings.Fields(zed.Prompt("copy as ("+strings.Join(copyFormats, ", ")+
+		") [to file]", tabbarscroll))
+	if len(fields) == 0 {
+		return
+	} else if len(fields) > 2 {
+		zed.Msg("Expected format [file]")
+		return
+	}
+	text, err := FormatBytes(zbuf.File.Read(start, length), strings.ToLower(fields[0]))
+	if err != nil {
+		zed.Error(err)
+		return
+	}
+	zbuf.MarkActive = false
+	if len(fields) == 2 {
+		path, err := homedir.Expand(fields[1])
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(text+"\n"), 0644)
+		}
+		if err != nil {
+			zed.Msg("Can't write %s: %s", fields[1], err.Error())
+			return
+		}
+		zed.Msg("Wrote %d bytes as %s to %s", length, fields[0], fields[1])
+		return
+	}
+	if len(text) > ZOSC52Max {
+		zed.Msg("Too big for the clipboard (%d chars); give a file to write to", len(text))
+		return
+	}
+	if err := osc52(text); err != nil {
+		zed.Msg("Can't copy to clipboard: %s", err.Error())
+		return
+	}
+	zed.Msg("Copied %d bytes as %s to clipboard", length, fields[0])
+}
@@ -36,25 +36,25 @@ func (tree *ZBufTree) SetFocus(which int) {
 	tree.GetFocus().Buf = which
 }
 
-func (tree *ZBufTree) Click(zed *ZerzEditor, x1, y1, x2, y2, mx, my int) {
+func (tree *ZBufTree) Click(zed *ZerzEditor, x1, y1, x2, y2, mx, my int, drag bool) {
 	if !(x1 <= mx && mx <= x2 && y1 <= my && my <= y2) {
 		// Do nothing
 		return
 	} else if tree.Split {
 		if tree.Hor {
 			chunk := ((x2 - x1) / 2)
-			tree.ChildLT.Click(zed, x1, y1, (x2-1)-chunk, y2, mx, my)
-			tree.ChildRB.Click(zed, (x2-chunk)+1, y1, x2, y2, mx, my)
+			tree.ChildLT.Click(zed, x1, y1, (x2-1)-chunk, y2, mx, my, drag)
+			tree.ChildRB.Click(zed, (x2-chunk)+1, y1, x2, y2, mx, my, drag)
 		} else {
 			chunk := ((y2 - y1) / 2)
-			tree.ChildLT.Click(zed, x1, y1, x2, (y2-1)-chunk, mx, my)
-			tree.ChildRB.Click(zed, x1, (y2-chunk)+1, x2, y2, mx, my)
+			tree.ChildLT.Click(zed, x1, y1, x2, (y2-1)-chunk, mx, my, drag)
+			tree.ChildRB.Click(zed, x1, (y2-chunk)+1, x2, y2, mx, my, drag)
 		}
 	} else {
 		if !tree.Focused {
 			tree.SiezeFocus(zed)
 		}
-		zed.Buffers[tree.Buf].Click(x1, y1, mx, my)
+		zed.Buffers[tree.Buf].Click(x1, y1, mx, my, drag)
 	}
 }
 
@@ -132,9 +132,23 @@ func (tree *ZBufTree) SetFocusToBotMost(zed *ZerzEditor) {
 }
 
 type ZerzEditor struct {
-	Buffers []*ZerzBuffer
-	CurBuf  int
-	Tree    *ZBufTree
+	Buffers     []*ZerzBuffer
+	CurBuf      int
+	Tree        *ZBufTree
+	KillRing    [][]byte
+	KillFill    byte
+	yankIndex   int
+	lastYank    *ZerzUndo
+	Recording   bool
+	Macro       []ZerzMacroStep
+	LastMacro   []ZerzMacroStep
+	playback    []ZerzMacroStep
+	playPos     int
+	Echo        string
+	Messages    []string
+	LastSearch  ZerzMatcher
+	LastISearch textMatcher
+	lastCount   zerzCount
 }
 
 func InitEditor(filenames []string) (*ZerzEditor, []error) {
@@ -145,7 +159,9 @@ func InitEditor(filenames []string) (*ZerzEditor, []error) {
 		buf, err := CreateBuffer(filename)
 		if err == nil {
 			buffers = append(buffers, buf)
-		} else {
+			buf.Recovered, err = LoadJournal(buf.File.Filepath)
+		}
+		if err != nil {
 			errors = append(errors, fmt.Errorf("%s: %s", filename, err.Error()))
 		}
 	}
@@ -154,7 +170,8 @@ func InitEditor(filenames []string) (*ZerzEditor, []error) {
 		buffers[0].Focused = true
 	}
 
-	return &ZerzEditor{buffers, 0, &ZBufTree{false, false, true, 0, nil, nil, nil}},
+	return &ZerzEditor{Buffers: buffers,
+			Tree: &ZBufTree{false, false, true, 0, nil, nil, nil}},
 		errors
 }
 
@@ -164,6 +181,59 @@ func (zed *ZerzEditor) Close() {
 	}
 }
 
+func (zed *ZerzEditor) Modified() bool {
+	for _, buffer := range zed.Buffers {
+		if buffer.Modified() {
+			return true
+		}
+	}
+	return false
+}
+
+func (zed *ZerzEditor) Prompt(prompt string, tabbarscroll int) string {
+	if value, ok := zed.macroAnswer(); ok {
+		return value
+	}
+	value := termutil.Prompt(prompt, func(sx, sy int) {
+		zed.Draw(tabbarscroll, sx, sy, 0, 2, sx, sy-2)
+	})
+	termbox.HideCursor()
+	zed.recordAnswer(value)
+	return value
+}
+
+func (zed *ZerzEditor) YesNo(prompt string, tabbarscroll int) bool {
+	value := zed.Prompt(prompt+" (y/n)", tabbarscroll)
+	return len(value) > 0 && value[0]|0x20 == 'y'
+}
+
+func (zed *ZerzEditor) ConfirmQuit(tabbarscroll int) bool {
+	return !zed.Modified() || zed.YesNo("Modified buffers exist; quit anyway?", tabbarscroll)
+}
+
+func (zed *ZerzEditor) SaveFocusBuf() error {
+	buf := zed.FocusBuf()
+	if !buf.Modified() {
+		zed.Msg("No changes need to be saved")
+		return nil
+	}
+	if err := buf.Save(); err != nil {
+		return fmt.Errorf("%s: %s", buf.File.Filename, err.Error())
+	}
+	zed.Msg("Wrote %s (%d bytes)", buf.File.Filename, buf.File.Size)
+	return nil
+}
+
+func (zed *ZerzEditor) RevertFocusBuf(tabbarscroll int) {
+	buf := zed.FocusBuf()
+	if !buf.Modified() {
+		zed.Msg("No changes to revert")
+	} else if zed.YesNo("Discard changes to "+buf.File.Filename+"?", tabbarscroll) {
+		buf.Revert()
+		zed.Msg("Reverted %s", buf.File.Filename)
+	}
+}
+
 func (zed *ZerzEditor) FocusBuf() *ZerzBuffer {
 	return zed.Buffers[zed.CurBuf]
 }
@@ -181,10 +251,22 @@ func (zed *ZerzEditor) Draw(tabbarscroll, sx, sy, x1, y1, x2, y2 int) {
 		termbox.SetCell(i, sy-1, ' ', ZStatFg, ZStatBg)
 		termbox.SetCell(i, 1, ZLineHor, ZFgColor, ZBgColor)
 	}
-	termutil.PrintStringFgBg(0, sy-1, fmt.Sprintf("%s | %s | Offset: %016x | %s",
-		zed.FocusBuf().GetCursorData(), zed.FocusBuf().File.Filename,
-		zed.FocusBuf().Offset, zed.FocusBuf().EndStr()),
-		ZStatFg, ZStatBg)
+	termutil.PrintStringFgBg(0, sy-1, fmt.Sprintf("%s | %s %s | Offset: %016x | %s | %s",
+		zed.FocusBuf().GetCursorData(), zed.FocusBuf().ModStr(), zed.FocusBuf().File.Filename,
+		zed.FocusBuf().Offset, zed.FocusBuf().EndStr(), zed.FocusBuf().InsStr())+
+		zed.FocusBuf().RegionStr()+zed.MacroStr(), ZStatFg, ZStatBg)
+	if buf := zed.FocusBuf(); buf.Mode == ModePattern {
+		bit := '0' + rune(buf.File.Get(buf.Offset)>>buf.Bit()&1)
+		termbox.SetCell(len("pattern: ")+int(buf.BitPos), sy-1, bit, ZCursorFg, ZCursorPattern)
+	}
+	if zed.Echo != "" {
+		// The echo area sits over the right of the status line
+		echox := sx - termutil.RunewidthStr(zed.Echo) - 1
+		if echox < 0 {
+			echox = 0
+		}
+		termutil.PrintStringFgBg(echox, sy-1, " "+zed.Echo, ZStatFg, ZStatBg)
+	}
 	i := tabbarscroll
 	tbx := 1
 	for j, buf := range zed.Buffers[tabbarscroll:] {
@@ -194,6 +276,9 @@ func (zed *ZerzEditor) Draw(tabbarscroll, sx, sy, x1, y1, x2, y2 int) {
 			termutil.PrintStringFgBg(tbx, 0, buf.File.Filename, ZFgColor, ZBgColor)
 		}
 		tbx += buf.File.FilenameWidth
+		if buf.Modified() {
+			termbox.SetCell(tbx, 0, '*', ZFgColor, ZBgColor)
+		}
 		termbox.SetCell(tbx+1, 0, ZLineVert, ZFgColor, ZBgColor)
 		termbox.SetCell(tbx+1, 1, ZLineTUH, ZFgColor, ZBgColor)
 		tbx += 3
@@ -207,14 +292,18 @@ func (zed *ZerzEditor) Draw(tabbarscroll, sx, sy, x1, y1, x2, y2 int) {
 	zed.Tree.Draw(zed, x1, y1, x2, y2)
 }
 
-func (zed *ZerzEditor) PageUp(x1, y1, x2, y2 int) {
+func (zed *ZerzEditor) PageUp(x1, y1, x2, y2 int, n int64) {
 	_, _, yy1, _, yy2 := zed.Tree.GetFocusBufDimensions(x1, y1, x2, y2)
-	zed.FocusBuf().PageUp(yy1, yy2)
+	for i := int64(0); i < n && zed.FocusBuf().Scroll > 0; i++ {
+		zed.FocusBuf().PageUp(yy1, yy2)
+	}
 }
 
-func (zed *ZerzEditor) PageDown(x1, y1, x2, y2 int) {
+func (zed *ZerzEditor) PageDown(x1, y1, x2, y2 int, n int64) {
 	_, _, yy1, _, yy2 := zed.Tree.GetFocusBufDimensions(x1, y1, x2, y2)
-	zed.FocusBuf().PageDown(yy1, yy2)
+	for i := int64(0); i < n && zed.FocusBuf().Offset < zed.FocusBuf().File.Size-1; i++ {
+		zed.FocusBuf().PageDown(yy1, yy2)
+	}
 }
 
 func (zed *ZerzEditor) DoScroll(x1, y1, x2, y2 int) {
@@ -243,7 +332,7 @@ func (zed *ZerzEditor) HSplit() {
 	ftree.ChildRB = &ZBufTree{false, false, false, ftree.Buf, nil, nil, ftree}
 }
 
-func (zed *ZerzEditor) SplitUp() {
+func (zed *ZerzEditor) SplitUp() bool {
 	ftree := zed.Tree.GetFocus()
 	parent := ftree.Parent
 	child := ftree
@@ -251,14 +340,15 @@ func (zed *ZerzEditor) SplitUp() {
 		if !parent.Hor && parent.ChildRB == child {
 			parent.ChildLT.SetFocusToBotMost(zed)
 			ftree.Focused = false
-			return
+			return true
 		}
 		child = parent
 		parent = parent.Parent
 	}
+	return false
 }
 
-func (zed *ZerzEditor) SplitDown() {
+func (zed *ZerzEditor) SplitDown() bool {
 	ftree := zed.Tree.GetFocus()
 	parent := ftree.Parent
 	child := ftree
@@ -266,14 +356,15 @@ func (zed *ZerzEditor) SplitDown() {
 		if !parent.Hor && parent.ChildLT == child {
 			parent.ChildRB.SetFocusToTopMost(zed)
 			ftree.Focused = false
-			return
+			return true
 		}
 		child = parent
 		parent = parent.Parent
 	}
+	return false
 }
 
-func (zed *ZerzEditor) SplitLeft() {
+func (zed *ZerzEditor) SplitLeft() bool {
 	ftree := zed.Tree.GetFocus()
 	parent := ftree.Parent
 	child := ftree
@@ -281,14 +372,15 @@ func (zed *ZerzEditor) SplitLeft() {
 		if parent.Hor && parent.ChildRB == child {
 			parent.ChildLT.SetFocusToBotMost(zed)
 			ftree.Focused = false
-			return
+			return true
 		}
 		child = parent
 		parent = parent.Parent
 	}
+	return false
 }
 
-func (zed *ZerzEditor) SplitRight() {
+func (zed *ZerzEditor) SplitRight() bool {
 	ftree := zed.Tree.GetFocus()
 	parent := ftree.Parent
 	child := ftree
@@ -296,19 +388,20 @@ func (zed *ZerzEditor) SplitRight() {
 		if parent.Hor && parent.ChildLT == child {
 			parent.ChildRB.SetFocusToTopMost(zed)
 			ftree.Focused = false
-			return
+			return true
 		}
 		child = parent
 		parent = parent.Parent
 	}
+	return false
 }
 
-func (zed *ZerzEditor) KillSplit() {
+func (zed *ZerzEditor) KillSplit() bool {
 	ftree := zed.Tree.GetFocus()
 	parent := ftree.Parent
 	if parent == nil {
 		// Can't kill if there's no splits!
-		return
+		return false
 	}
 
 	var child *ZBufTree
@@ -323,8 +416,9 @@ func (zed *ZerzEditor) KillSplit() {
 	parent.Split = false
 	parent.ChildLT = nil
 	parent.ChildRB = nil
+	return true
 }
 
-func (zed *ZerzEditor) Click(x1, y1, x2, y2, mx, my int) {
-	zed.Tree.Click(zed, x1, y1, x2, y2, mx, my)
+func (zed *ZerzEditor) Click(x1, y1, x2, y2, mx, my int, drag bool) {
+	zed.Tree.Click(zed, x1, y1, x2, y2, mx, my, drag)
 }
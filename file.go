@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -18,37 +19,109 @@ type ZerzFile struct {
 	Filepath      string
 	Bytes         mmap.MMap
 	Size          int64
+	Pieces        []zerzPiece
+	Added         []byte
+	starts        []int64
 }
 
-func OpenFile(filename string) (*ZerzFile, error) {
-	absname, err := AbsPath(filename)
-	if err != nil {
-		return nil, fmt.Errorf("Can't get abspath: %s", err.Error())
-	}
-
+func mapFile(absname string) (*os.File, mmap.MMap, int64, error) {
 	file, err := os.OpenFile(absname, os.O_RDWR, 0)
 	if err != nil {
-		return nil, fmt.Errorf("Can't open file: %s", err.Error())
+		return nil, nil, 0, fmt.Errorf("Can't open file: %s", err.Error())
 	}
 
 	fs, err := file.Stat()
 	if err != nil {
 		file.Close()
-		return nil, fmt.Errorf("Can't stat file: %s", err.Error())
+		return nil, nil, 0, fmt.Errorf("Can't stat file: %s", err.Error())
 	}
 
-	mm, err := mmap.Map(file, mmap.RDWR, 0)
+	// Nothing is ever written through the map; edits live in the piece table
+	// until they're saved.
+	mm, err := mmap.Map(file, mmap.RDONLY, 0)
 	if err != nil {
 		file.Close()
-		return nil, fmt.Errorf("Can't mmap file: %s", err.Error())
+		return nil, nil, 0, fmt.Errorf("Can't mmap file: %s", err.Error())
+	}
+
+	return file, mm, fs.Size(), nil
+}
+
+func OpenFile(filename string) (*ZerzFile, error) {
+	absname, err := AbsPath(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get abspath: %s", err.Error())
+	}
+
+	file, mm, size, err := mapFile(absname)
+	if err != nil {
+		return nil, err
 	}
 
 	ret := ZerzFile{Filename: filepath.Base(absname), Filepath: absname,
-		Size: fs.Size(), Bytes: mm, File: file}
+		Size: size, Bytes: mm, File: file}
 	ret.FilenameWidth = termutil.RunewidthStr(ret.Filename)
+	ret.resetPieces()
 	return &ret, nil
 }
 
+// Writes the file out to a temporary file next to it, renames that over the
+// original, and maps the result.
+func (zfile *ZerzFile) Save() error {
+	target, err := filepath.EvalSymlinks(zfile.Filepath)
+	if err != nil {
+		return fmt.Errorf("Can't resolve path: %s", err.Error())
+	}
+	fs, err := zfile.File.Stat()
+	if err != nil {
+		return fmt.Errorf("Can't stat file: %s", err.Error())
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".zerz")
+	if err != nil {
+		return fmt.Errorf("Can't create temp file: %s", err.Error())
+	}
+	for _, piece := range zfile.Pieces {
+		if _, err = tmp.Write(zfile.source(piece)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Chmod(fs.Mode().Perm())
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Can't write temp file: %s", err.Error())
+	}
+	if err = os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Can't rename temp file: %s", err.Error())
+	}
+
+	file, mm, size, err := mapFile(target)
+	if err != nil {
+		// The old mapping is still good, so carry on with that.
+		return fmt.Errorf("Saved, but %s", err.Error())
+	}
+	zfile.Close()
+	zfile.File = file
+	zfile.Bytes = mm
+	zfile.Size = size
+	zfile.resetPieces()
+	return nil
+}
+
+func (zfile *ZerzFile) Revert() {
+	zfile.Size = int64(len(zfile.Bytes))
+	zfile.resetPieces()
+}
+
 func (zfile *ZerzFile) Close() {
 	zfile.Bytes.Unmap()
 	zfile.File.Close()
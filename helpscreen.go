@@ -5,6 +5,37 @@ import (
 	termbox "github.com/nsf/termbox-go"
 )
 
+var helplines = []string{
+	" BYTE: ←    ^B →    ^F |  Endian:    e | Beg of Line: Home/^A",
+	" WORD: ←   M-b →   M-f | Jump to:  M-g | End of Line:  End/^E",
+	"DWORD: ← C-M-b → C-M-f | Search: C-s/r | Beg of File:     M-<",
+	"PARAG: ↓    ^N ↑    ^B | Size-/+:  H/L | End of File:     M->",
+	"MODES:    BITS: p |   INT: i |   UINT: u |   CHAR: c",
+	"Type straight in: C-t toggles typing (mode keys off) | Hex/text: Tab",
+	"BITS mode: h/l move bit cursor | SPC toggle | 1 set | 0 clear",
+	" UNDO: C-_  REDO: C-M-_ | Save: C-x C-s | Revert: C-x C-r",
+	"  INS/OVR: Insert | Delete: C-d/Del | Delete back: Backspace",
+	"     Mark: C-SPC | Unmark:  C-g | Swap w/ mark:  C-x C-x",
+	" Copy: M-w | Cut: C-w | Yank: C-y | Older: M-y | Cut fill: C-x w",
+	"Region or range commands:  Fill: C-x f | xor/add/rotate: C-x x",
+	"                           Byte-swap elements (b) or words (w): C-x b",
+	"                           Move block: C-x v | Swap blocks: C-x V",
+	"Write bytes as hex, C array, \\x escapes or base64: C-x p",
+	"Copy range as Go, C, Python, hex or base64 to clipboard/file: C-x c",
+	"Truncate at cursor: C-x t | Extend file: C-x g",
+	"String encoding and terminator for CHAR mode: C-x s",
+	"Numeric argument: C-u [digits or 0x...], or M-digits",
+	"Show the *Messages* history: C-x m",
+	"Search patterns: 48 8b ?? 89 | 4? nibbles | 80/c0 value/mask",
+	"Regexp search over bytes: C-M-s/C-M-r (. is any byte, \\xNN a byte)",
+	"  (empty search repeats the last one; C-g clears highlighting)",
+	"Search for a value as the mode encodes it: M-n (\"N all\" lists every width)",
+	"Incremental text search: M-s, then C-s/C-r next/prev, M-c case, M-w word",
+	"Switch buffer: C-x 1-9 | Kill split: C-x 0",
+	"Macro: C-x ( start, C-x ) stop, C-x e play, C-x E repeat",
+	"       C-x n name & save last, C-x r run saved",
+}
+
 func helpscreen() {
 	motto := "“For the Wild!”"
 	motw := termutil.RunewidthStr(motto)
@@ -14,7 +45,7 @@ func helpscreen() {
 	th, tw := 5, 27
 	for {
 		termbox.Sync()
-		sx, _ := termbox.Size()
+		sx, sy := termbox.Size()
 		termbox.Clear(ZHelpFg, ZHelpBg)
 		// Title & motto
 		tx, ty := (sx/2)-(tw/2), 0
@@ -31,8 +62,13 @@ func helpscreen() {
 		termutil.PrintStringFgBg((sx/2)-(motw/2), ty+5, motto, ZHelpFg, ZHelpBg)
 		// Green anarchist flag >:)
 		fx, fy := (sx/2)-(fw/2), th+2
+		flagh := fh
+		if sy < fy+fh+1+len(helplines) {
+			// Not enough room for the flag; the keys are more important.
+			flagh = 0
+		}
 		i := fw - 1
-		for y := 0; y < fh; y++ {
+		for y := 0; y < flagh; y++ {
 			for x := 0; x < fw; x++ {
 				if x == i {
 					termbox.SetCell(fx+x, fy+y, ZFlagTri,
@@ -49,11 +85,9 @@ func helpscreen() {
 		}
 		// Actual help
 		xanc := (sx / 2) - ((arroww + lownybw) / 2)
-		termutil.PrintStringFgBg(xanc, fy+fh+1, " BYTE: ←    ^B →    ^F |  Endian:    e | Beg of Line: Home/^A", ZHelpFg, ZHelpBg)
-		termutil.PrintStringFgBg(xanc, fy+fh+2, " WORD: ←   M-b →   M-f | Jump to:  M-g | End of Line:  End/^E", ZHelpFg, ZHelpBg)
-		termutil.PrintStringFgBg(xanc, fy+fh+3, "DWORD: ← C-M-b → C-M-f |  Search:  C-s | Beg of File:     M-<", ZHelpFg, ZHelpBg)
-		termutil.PrintStringFgBg(xanc, fy+fh+4, "PARAG: ↓    ^N ↑    ^B | Size-/+:  H/L | End of File:     M->", ZHelpFg, ZHelpBg)
-		termutil.PrintStringFgBg(xanc, fy+fh+5, "MODES:    BITS: p |   INT: i |   UINT: u |   CHAR: c", ZHelpFg, ZHelpBg)
+		for i, line := range helplines {
+			termutil.PrintStringFgBg(xanc, fy+flagh+1+i, line, ZHelpFg, ZHelpBg)
+		}
 		termbox.Flush()
 
 		ev := termbox.PollEvent()
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	termutil "github.com/japanoise/termbox-util"
+	termbox "github.com/nsf/termbox-go"
+)
+
+// ASCII text, optionally ignoring case and matching only whole words.
+type textMatcher struct {
+	Text []byte
+	Fold bool
+	Word bool
+}
+
+func asciiLower(data []byte) []byte {
+	ret := make([]byte, len(data))
+	for i, b := range data {
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		ret[i] = b
+	}
+	return ret
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func (m textMatcher) prepare(data []byte) ([]byte, []byte) {
+	if m.Fold {
+		return asciiLower(data), asciiLower(m.Text)
+	}
+	return data, m.Text
+}
+
+func (m textMatcher) wordAt(data []byte, start, end int) bool {
+	return !m.Word || ((start == 0 || !isWordByte(data[start-1])) &&
+		(end == len(data) || !isWordByte(data[end])))
+}
+
+func (m textMatcher) Index(data []byte, from int) []int {
+	data, text := m.prepare(data)
+	for from <= len(data) {
+		i := bytes.Index(data[from:], text)
+		if i < 0 {
+			return nil
+		}
+		if m.wordAt(data, from+i, from+i+len(text)) {
+			return []int{from + i, from + i + len(text)}
+		}
+		from += i + 1
+	}
+	return nil
+}
+
+func (m textMatcher) LastIndex(data []byte, to int) []int {
+	data, text := m.prepare(data)
+	limit := to + len(text) - 1
+	if limit > len(data) {
+		limit = len(data)
+	}
+	for limit >= 0 {
+		i := bytes.LastIndex(data[:limit], text)
+		if i < 0 {
+			return nil
+		}
+		if m.wordAt(data, i, i+len(text)) {
+			return []int{i, i + len(text)}
+		}
+		limit = i + len(text) - 1
+	}
+	return nil
+}
+
+func (m textMatcher) MaxLen() int {
+	return len(m.Text)
+}
+
+func (m textMatcher) String() string {
+	return string(m.Text)
+}
+
+// State of an incremental search while it's going on.
+type zerzISearch struct {
+	matcher  textMatcher
+	backward bool
+	start    int64
+	scroll   int64
+	failing  bool
+	wrapped  bool
+}
+
+func (loop *zerzLoop) StartISearch() {
+	buf := loop.global.FocusBuf()
+	loop.isearch = &zerzISearch{start: buf.Offset, scroll: buf.Scroll,
+		matcher: textMatcher{Fold: true}}
+}
+
+func (loop *zerzLoop) endISearch() {
+	is := loop.isearch
+	loop.isearch = nil
+	loop.global.FocusBuf().Highlight = nil
+	if len(is.matcher.Text) > 0 {
+		loop.global.LastISearch = is.matcher
+	}
+}
+
+// Looks for the search text from the cursor. When the text has just grown,
+// the match at the cursor can still count; when stepping to the next match,
+// it can't.
+func (loop *zerzLoop) isearchFind(next bool) {
+	is := loop.isearch
+	buf := loop.global.FocusBuf()
+	if len(is.matcher.Text) == 0 {
+		is.failing = false
+		buf.Highlight = nil
+		return
+	}
+	buf.Highlight = is.matcher
+	from := buf.Offset
+	if !next && !is.backward {
+		from--
+	} else if !next {
+		from++
+	}
+	at, _, wrap := buf.File.Find(is.matcher, from, is.backward)
+	if at < 0 || (wrap && !next) {
+		// Only step round the end of the file when asked to
+		is.failing = true
+		return
+	}
+	is.failing = false
+	is.wrapped = is.wrapped || wrap
+	_, _, y1, _, y2 := loop.global.Tree.GetFocusBufDimensions(0, 2, loop.sx, loop.sy-2)
+	buf.JumpTo(at, y1, y2)
+}
+
+// Handles an event during an incremental search. Returns false if the event
+// ended the search and should be handled as usual.
+func (loop *zerzLoop) isearchEvent(event termbox.Event) bool {
+	is := loop.isearch
+	buf := loop.global.FocusBuf()
+	switch {
+	case event.Type == termbox.EventResize:
+		loop.sync()
+	case event.Type != termbox.EventKey:
+		loop.endISearch()
+		return false
+	case event.Ch != 0 && event.Mod == 0:
+		is.matcher.Text = append(is.matcher.Text, string(event.Ch)...)
+		loop.isearchFind(false)
+	case event.Key == termbox.KeySpace && event.Mod == 0:
+		is.matcher.Text = append(is.matcher.Text, ' ')
+		loop.isearchFind(false)
+	case event.Key == termbox.KeyBackspace || event.Key == termbox.KeyBackspace2:
+		if len(is.matcher.Text) > 0 {
+			is.matcher.Text = is.matcher.Text[:len(is.matcher.Text)-1]
+		}
+		buf.Offset, is.wrapped = is.start, false
+		loop.isearchFind(false)
+	case event.Key == termbox.KeyCtrlS || event.Key == termbox.KeyCtrlR:
+		is.backward = event.Key == termbox.KeyCtrlR
+		if len(is.matcher.Text) == 0 && loop.global.LastISearch.Text != nil {
+			is.matcher = loop.global.LastISearch
+			loop.isearchFind(false)
+		} else {
+			loop.isearchFind(true)
+		}
+	case event.Mod == termbox.ModAlt && event.Ch == 'c':
+		is.matcher.Fold = !is.matcher.Fold
+		loop.isearchFind(false)
+	case event.Mod == termbox.ModAlt && event.Ch == 'w':
+		is.matcher.Word = !is.matcher.Word
+		loop.isearchFind(false)
+	case event.Key == termbox.KeyEnter:
+		loop.endISearch()
+	case event.Key == termbox.KeyCtrlG:
+		buf.Offset, buf.Scroll = is.start, is.scroll
+		loop.endISearch()
+	default:
+		loop.endISearch()
+		return false
+	}
+	return true
+}
+
+func (loop *zerzLoop) drawISearch() {
+	is := loop.isearch
+	prompt := "I-search"
+	if is.backward {
+		prompt += " backward"
+	}
+	if is.wrapped {
+		prompt = "Wrapped " + prompt
+	}
+	if is.failing {
+		prompt = "Failing " + prompt
+	}
+	opts := []string{}
+	if !is.matcher.Fold {
+		opts = append(opts, "case")
+	}
+	if is.matcher.Word {
+		opts = append(opts, "word")
+	}
+	if len(opts) > 0 {
+		prompt += " (" + strings.Join(opts, ", ") + ")"
+	}
+	prompt += ": " + string(is.matcher.Text)
+	for i := 0; i < loop.sx; i++ {
+		termbox.SetCell(i, loop.sy-1, ' ', ZStatFg, ZStatBg)
+	}
+	termutil.PrintStringFgBg(0, loop.sy-1, prompt, ZStatFg, ZStatBg)
+	hint := "M-c case | M-w word"
+	termutil.PrintStringFgBg(loop.sx-len(hint)-1, loop.sy-1, hint, ZStatFg, ZStatBg)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const ZKillRingMax = 32
+
+func (zed *ZerzEditor) pushKill(data []byte) {
+	zed.KillRing = append(zed.KillRing, data)
+	if len(zed.KillRing) > ZKillRingMax {
+		zed.KillRing = zed.KillRing[1:]
+	}
+	zed.yankIndex = len(zed.KillRing) - 1
+}
+
+func (zed *ZerzEditor) CopyRegion() {
+	buf := zed.FocusBuf()
+	start, length, ok := buf.Region()
+	if !ok {
+		zed.Msg("No region")
+		return
+	}
+	zed.pushKill(buf.File.Read(start, length))
+	buf.MarkActive = false
+	zed.Msg("Copied %d bytes", length)
+}
+
+// Copies the region, then blanks it with KillFill, or deletes it in insert
+// mode.
+func (zed *ZerzEditor) CutRegion() {
+	buf := zed.FocusBuf()
+	start, length, ok := buf.Region()
+	if !ok {
+		zed.Msg("No region")
+		return
+	} else if buf.Insert && length >= buf.File.Size {
+		zed.Msg("Can't cut the whole file in insert mode")
+		return
+	}
+	zed.pushKill(buf.File.Read(start, length))
+	buf.MarkActive = false
+	buf.Offset = start
+	if buf.Insert {
+		buf.DeleteBytes(start, length)
+		zed.Msg("Cut %d bytes", length)
+		return
+	}
+	fill := make([]byte, length)
+	for i := range fill {
+		fill[i] = zed.KillFill
+	}
+	buf.WriteBytes(start, fill)
+	zed.Msg("Cut %d bytes, leaving %02x", length, zed.KillFill)
+}
+
+func (zed *ZerzEditor) yank(tabbarscroll int) bool {
+	buf := zed.FocusBuf()
+	data := zed.KillRing[zed.yankIndex]
+	if over := buf.Offset + int64(len(data)) - buf.File.Size; !buf.Insert && over > 0 {
+		if !zed.YesNo(fmt.Sprintf("Paste runs %d bytes past end of file; clip it?",
+			over), tabbarscroll) {
+			return false
+		}
+	}
+	before := buf.Offset
+	n := buf.PutBytes(data)
+	if n > 0 {
+		zed.lastYank = buf.UndoList[len(buf.UndoList)-1]
+		buf.ForwardByte(int64(n))
+		zed.Msg("Pasted %d bytes", n)
+	}
+	// Another paste only makes sense if this one fitted and there's room after
+	return n == len(data) && buf.Offset == before+int64(n)
+}
+
+func (zed *ZerzEditor) Yank(tabbarscroll int) bool {
+	if len(zed.KillRing) == 0 {
+		zed.Msg("Kill ring is empty")
+		return false
+	}
+	zed.yankIndex = len(zed.KillRing) - 1
+	return zed.yank(tabbarscroll)
+}
+
+// Swaps the text just yanked for the next older entry in the kill ring.
+func (zed *ZerzEditor) YankPop(tabbarscroll int) {
+	buf := zed.FocusBuf()
+	if len(buf.UndoList) == 0 || buf.UndoList[len(buf.UndoList)-1] != zed.lastYank {
+		zed.Msg("Previous command was not a yank")
+		return
+	}
+	buf.Undo()
+	zed.yankIndex--
+	if zed.yankIndex < 0 {
+		zed.yankIndex = len(zed.KillRing) - 1
+	}
+	zed.yank(tabbarscroll)
+}
+
+func (zed *ZerzEditor) SetKillFill(tabbarscroll int) {
+	value := zed.Prompt(fmt.Sprintf("cut fill byte (hex, currently %02x)", zed.KillFill),
+		tabbarscroll)
+	if value == "" {
+		return
+	}
+	result, err := strconv.ParseUint(value, 16, 8)
+	if err != nil {
+		zed.Msg("Bad fill byte %q: %s", value, numErr(err))
+		return
+	}
+	zed.KillFill = byte(result)
+}
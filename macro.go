@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	termbox "github.com/nsf/termbox-go"
+)
+
+const ZMacroFile = "~/.config/zerz/macros.json"
+
+// One step of a keyboard macro: either an event handled by the main loop, or
+// the answer typed into a prompt that event brought up.
+type ZerzMacroStep struct {
+	Type   termbox.EventType `json:",omitempty"`
+	Mod    termbox.Modifier  `json:",omitempty"`
+	Key    termbox.Key       `json:",omitempty"`
+	Ch     rune              `json:",omitempty"`
+	MouseX int               `json:",omitempty"`
+	MouseY int               `json:",omitempty"`
+	Prompt bool              `json:",omitempty"`
+	Answer string            `json:",omitempty"`
+}
+
+func (step ZerzMacroStep) Event() termbox.Event {
+	return termbox.Event{Type: step.Type, Mod: step.Mod, Key: step.Key, Ch: step.Ch,
+		MouseX: step.MouseX, MouseY: step.MouseY}
+}
+
+func (zed *ZerzEditor) RecordEvent(event termbox.Event) {
+	if !zed.Recording || (event.Type != termbox.EventKey && event.Type != termbox.EventMouse) {
+		return
+	}
+	zed.Macro = append(zed.Macro, ZerzMacroStep{Type: event.Type, Mod: event.Mod,
+		Key: event.Key, Ch: event.Ch, MouseX: event.MouseX, MouseY: event.MouseY})
+}
+
+func (zed *ZerzEditor) recordAnswer(value string) {
+	if zed.Recording {
+		zed.Macro = append(zed.Macro, ZerzMacroStep{Prompt: true, Answer: value})
+	}
+}
+
+// If a macro is playing and its next step answers a prompt, returns that.
+func (zed *ZerzEditor) macroAnswer() (string, bool) {
+	if zed.playPos < len(zed.playback) && zed.playback[zed.playPos].Prompt {
+		zed.playPos++
+		return zed.playback[zed.playPos-1].Answer, true
+	}
+	return "", false
+}
+
+func (zed *ZerzEditor) MacroStr() string {
+	if zed.Recording {
+		return " | Def"
+	}
+	return ""
+}
+
+func (zed *ZerzEditor) Playing() bool {
+	return zed.playback != nil
+}
+
+func (zed *ZerzEditor) StartMacro() {
+	if zed.Recording {
+		zed.Msg("Already defining a macro")
+		return
+	} else if zed.Playing() {
+		return
+	}
+	zed.Recording = true
+	zed.Macro = nil
+	zed.Msg("Defining macro...")
+}
+
+func (zed *ZerzEditor) EndMacro() {
+	if !zed.Recording {
+		zed.Msg("Not defining a macro")
+		return
+	}
+	zed.Recording = false
+	// The C-x ) that ended the macro was recorded too; drop it.
+	if len(zed.Macro) >= 2 {
+		zed.LastMacro = zed.Macro[:len(zed.Macro)-2]
+		zed.Msg("Macro defined (%d steps)", len(zed.LastMacro))
+	}
+	zed.Macro = nil
+}
+
+func macroPath() (string, error) {
+	return homedir.Expand(ZMacroFile)
+}
+
+func LoadMacros() (map[string][]ZerzMacroStep, error) {
+	macros := make(map[string][]ZerzMacroStep)
+	path, err := macroPath()
+	if err != nil {
+		return macros, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return macros, nil
+	} else if err != nil {
+		return macros, err
+	}
+	err = json.Unmarshal(data, &macros)
+	return macros, err
+}
+
+func SaveMacros(macros map[string][]ZerzMacroStep) error {
+	path, err := macroPath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(macros, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Names the last macro and saves it to the macro file with the others.
+func (zed *ZerzEditor) NameMacro(tabbarscroll int) error {
+	if zed.LastMacro == nil {
+		zed.Msg("No macro defined")
+		return nil
+	}
+	name := strings.TrimSpace(zed.Prompt("name for last macro", tabbarscroll))
+	if name == "" {
+		return nil
+	}
+	macros, err := LoadMacros()
+	if err != nil {
+		return fmt.Errorf("Can't load macros: %s", err.Error())
+	}
+	macros[name] = zed.LastMacro
+	if err = SaveMacros(macros); err != nil {
+		return fmt.Errorf("Can't save macros: %s", err.Error())
+	}
+	zed.Msg("Saved macro %q", name)
+	return nil
+}
+
+// Feeds a macro's events back through the loop, times times over, or until
+// the cursor reaches the end of the file (or stops moving) if times is -1.
+// Either way it stops early if a pass changes nothing.
+func (loop *zerzLoop) playMacro(macro []ZerzMacroStep, times int) {
+	global := loop.global
+	if global.Recording || global.Playing() || len(macro) == 0 {
+		return
+	}
+	if times > ZRepeatMax {
+		times = ZRepeatMax
+	}
+	defer func() {
+		global.playback = nil
+		global.playPos = 0
+	}()
+	for i := 0; times < 0 || i < times; i++ {
+		buf := global.FocusBuf()
+		before, undo := buf.Offset, len(buf.UndoList)
+		var top *ZerzUndo
+		if undo > 0 {
+			top = buf.UndoList[undo-1]
+		}
+		global.playback = macro
+		global.playPos = 0
+		for global.playPos < len(macro) && !loop.done {
+			step := macro[global.playPos]
+			global.playPos++
+			if !step.Prompt {
+				loop.handleEvent(step.Event())
+			}
+		}
+		if loop.done {
+			return
+		}
+		if times < 0 && (buf != global.FocusBuf() || buf.Offset <= before ||
+			buf.Offset >= buf.File.Size-1) {
+			return
+		}
+		if buf == global.FocusBuf() && buf.Offset == before && len(buf.UndoList) == undo &&
+			(undo == 0 || buf.UndoList[undo-1] == top) {
+			return
+		}
+	}
+}
+
+// Parses a repeat count for a macro: a number, or "eof" to run to the end.
+func ParseRepeat(value string) (int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 1, true
+	} else if strings.ToLower(value) == "eof" {
+		return -1, true
+	}
+	times, err := strconv.Atoi(value)
+	return times, err == nil && times > 0
+}
+
+func (loop *zerzLoop) RepeatLastMacro() {
+	if loop.global.LastMacro == nil {
+		loop.global.Msg("No macro defined")
+		return
+	}
+	value := loop.global.Prompt("repeat last macro how many times (or eof)", loop.tabbarscroll)
+	if times, ok := ParseRepeat(value); ok {
+		loop.playMacro(loop.global.LastMacro, times)
+	} else {
+		loop.global.Msg("Bad repeat count %q", value)
+	}
+}
+
+func (loop *zerzLoop) PlayNamedMacro() error {
+	macros, err := LoadMacros()
+	if err != nil {
+		return fmt.Errorf("Can't load macros: %s", err.Error())
+	}
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	name := strings.TrimSpace(loop.global.Prompt("run macro ("+strings.Join(names, ", ")+")",
+		loop.tabbarscroll))
+	if macro, ok := macros[name]; ok {
+		loop.playMacro(macro, 1)
+	} else if name != "" {
+		loop.global.Msg("No macro named %q", name)
+	}
+	return nil
+}
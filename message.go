@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const ZMessagesMax = 500
+
+// Posts a message to the echo area, and keeps it in the *Messages* history.
+func (zed *ZerzEditor) Msg(format string, a ...interface{}) {
+	zed.Echo = fmt.Sprintf(format, a...)
+	zed.Messages = append(zed.Messages, zed.Echo)
+	if len(zed.Messages) > ZMessagesMax {
+		zed.Messages = zed.Messages[1:]
+	}
+}
+
+func (zed *ZerzEditor) Error(err error) {
+	zed.Msg("%s", err.Error())
+}
+
+// strconv's errors quote the function that failed; the reason is enough.
+func numErr(err error) error {
+	if nerr, ok := err.(*strconv.NumError); ok {
+		return nerr.Err
+	}
+	return err
+}
+
+func (zed *ZerzEditor) ShowMessages() {
+	messages := make([]string, len(zed.Messages))
+	for i, message := range zed.Messages {
+		messages[len(messages)-1-i] = message
+	}
+	infoBox("*Messages*", "Newest first:", messages)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Parses hex bytes like "de ad be ef", "deadbeef" or "de,ad,be,ef".
+func ParseHexBytes(value string) ([]byte, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == ',' || r == '\t' {
+			return -1
+		}
+		return r
+	}, value)
+	if digits == "" {
+		return nil, errors.New("no bytes given")
+	}
+	if len(digits)%2 != 0 {
+		return nil, errors.New("odd number of hex digits")
+	}
+	ret := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		b, err := strconv.ParseUint(digits[i:i+2], 16, 8)
+		if err != nil {
+			return nil, errors.New("bad hex byte " + digits[i:i+2])
+		}
+		ret = append(ret, byte(b))
+	}
+	return ret, nil
+}
+
+// Parses "start length" or just "length", with the start defaulting to def.
+func ParseRange(value string, def int64) (int64, int64, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, 0, errors.New("expected [start] length")
+	}
+	start := def
+	if len(fields) == 2 {
+		result, err := strconv.ParseInt(fields[0], 0, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		start = result
+		fields = fields[1:]
+	}
+	length, err := strconv.ParseInt(fields[0], 0, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start < 0 || length <= 0 {
+		return 0, 0, errors.New("range out of bounds")
+	}
+	return start, length, nil
+}
+
+// Parses an array like "{0x12, 0x34, 255}", "[]byte{0x12, 0x34}" or just
+// "0x12 0x34".
+func parseArrayBytes(value string) ([]byte, error) {
+	if i := strings.LastIndexAny(value, "{["); i >= 0 {
+		value = value[i+1:]
+	}
+	if i := strings.IndexAny(value, "}]"); i >= 0 {
+		value = value[:i]
+	}
+	ret := []byte{}
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		b, err := strconv.ParseUint(field, 0, 8)
+		if err != nil {
+			return nil, errors.New("bad array element " + field)
+		}
+		ret = append(ret, byte(b))
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("no bytes given")
+	}
+	return ret, nil
+}
+
+// Parses an escaped string like "\x12\x34" or "abc\0", with or without quotes.
+func parseEscapedBytes(value string) ([]byte, error) {
+	if strings.HasPrefix(value, "\"") {
+		if len(value) < 2 || value[len(value)-1] != '"' {
+			return nil, errors.New("unterminated string")
+		}
+		value = value[1 : len(value)-1]
+	} else if strings.HasPrefix(value, "b\"") || strings.HasPrefix(value, "b'") {
+		if len(value) < 3 || value[len(value)-1] != value[1] {
+			return nil, errors.New("unterminated string")
+		}
+		value = value[2 : len(value)-1]
+	}
+	ret := []byte{}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			ret = append(ret, value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'x':
+			if i+3 > len(value) {
+				return nil, errors.New("short \\x escape")
+			}
+			b, err := strconv.ParseUint(value[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, errors.New("bad \\x escape")
+			}
+			ret = append(ret, byte(b))
+			i += 2
+		case '0':
+			ret = append(ret, 0)
+		case 'n':
+			ret = append(ret, '\n')
+		case 'r':
+			ret = append(ret, '\r')
+		case 't':
+			ret = append(ret, '\t')
+		default:
+			ret = append(ret, value[i])
+		}
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("no bytes given")
+	}
+	return ret, nil
+}
+
+// Works out what notation a string of bytes is written in and decodes it.
+// Returns the bytes and the name of the notation.
+func ParseByteNotation(value string) ([]byte, string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return nil, "", errors.New("no bytes given")
+	case strings.Contains(value, "\\"), value[0] == '"', strings.HasPrefix(value, "b\""),
+		strings.HasPrefix(value, "b'"):
+		ret, err := parseEscapedBytes(value)
+		return ret, "escaped string", err
+	case strings.ContainsAny(value, "{}[]"), strings.Contains(strings.ToLower(value), "0x"):
+		ret, err := parseArrayBytes(value)
+		return ret, "array", err
+	}
+	if strings.Trim(value, "0123456789abcdefABCDEF ,\t") == "" {
+		// Looks like hex, so a mistake in it shouldn't turn into base64
+		ret, err := ParseHexBytes(value)
+		return ret, "hex", err
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding} {
+		if ret, err := enc.DecodeString(value); err == nil && len(ret) > 0 {
+			return ret, "base64", nil
+		}
+	}
+	return nil, "", errors.New("can't tell what notation that is")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseByteNotation(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []byte
+		kind string
+		bad  bool
+	}{
+		{in: "de ad be ef", want: []byte{0xde, 0xad, 0xbe, 0xef}, kind: "hex"},
+		{in: "{0x12, 0x34}", want: []byte{0x12, 0x34}, kind: "array"},
+		{in: `"ab\x00"`, want: []byte("ab\x00"), kind: "escaped string"},
+		{in: `b"a\n"`, want: []byte("a\n"), kind: "escaped string"},
+		{in: `b'a'`, want: []byte("a"), kind: "escaped string"},
+		{in: `b""`, bad: true},
+		{in: `""`, bad: true},
+		{in: `b"`, bad: true},
+		{in: `b'`, bad: true},
+		{in: `b"x`, bad: true},
+		{in: `b"x'`, bad: true},
+		{in: `"`, bad: true},
+		{in: `"abc`, bad: true},
+		{in: `\x1`, bad: true},
+		{in: "deadbee", bad: true},
+		{in: "de ad b", bad: true},
+		{in: "3q2+7w==", want: []byte{0xde, 0xad, 0xbe, 0xef}, kind: "base64"},
+		{in: "", bad: true},
+	}
+	for _, test := range tests {
+		got, kind, err := ParseByteNotation(test.in)
+		if test.bad {
+			if err == nil {
+				t.Errorf("%q: want an error, got %x (%s)", test.in, got, kind)
+			}
+			continue
+		}
+		if err != nil || kind != test.kind || !bytes.Equal(got, test.want) {
+			t.Errorf("%q: got %x (%s) %v, want %x (%s)", test.in, got, kind, err,
+				test.want, test.kind)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bytes that match where data&Mask == Value&Mask, for patterns with
+// wildcards in them.
+type maskMatcher struct {
+	Value []byte
+	Mask  []byte
+}
+
+func (m maskMatcher) matchAt(data []byte, i int) bool {
+	for j := range m.Value {
+		if data[i+j]&m.Mask[j] != m.Value[j]&m.Mask[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// The first byte that has to match exactly, if any, which can be skipped to
+// with IndexByte.
+func (m maskMatcher) anchor() int {
+	for j, mask := range m.Mask {
+		if mask == 0xFF {
+			return j
+		}
+	}
+	return -1
+}
+
+func (m maskMatcher) Index(data []byte, from int) []int {
+	k := m.anchor()
+	for i := from; i+len(m.Value) <= len(data); i++ {
+		if k >= 0 {
+			skip := bytes.IndexByte(data[i+k:], m.Value[k])
+			if skip < 0 || i+skip+len(m.Value) > len(data) {
+				return nil
+			}
+			i += skip
+		}
+		if m.matchAt(data, i) {
+			return []int{i, i + len(m.Value)}
+		}
+	}
+	return nil
+}
+
+func (m maskMatcher) LastIndex(data []byte, to int) []int {
+	i := len(data) - len(m.Value)
+	if i > to-1 {
+		i = to - 1
+	}
+	for ; i >= 0; i-- {
+		if m.matchAt(data, i) {
+			return []int{i, i + len(m.Value)}
+		}
+	}
+	return nil
+}
+
+func (m maskMatcher) MaxLen() int {
+	return len(m.Value)
+}
+
+func (m maskMatcher) String() string {
+	fields := make([]string, len(m.Value))
+	for i, v := range m.Value {
+		switch m.Mask[i] {
+		case 0xFF:
+			fields[i] = fmt.Sprintf("%02x", v)
+		case 0x00:
+			fields[i] = "??"
+		case 0xF0:
+			fields[i] = fmt.Sprintf("%x?", v>>4)
+		case 0x0F:
+			fields[i] = fmt.Sprintf("?%x", v&0x0F)
+		default:
+			fields[i] = fmt.Sprintf("%02x/%02x", v, m.Mask[i])
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// Parses one byte of a pattern, where either digit can be a ? wildcard.
+func parsePatternByte(digits string) (byte, byte, error) {
+	var value, mask byte
+	for i, shift := range []uint{4, 0} {
+		if digits[i] == '?' {
+			continue
+		}
+		d, err := strconv.ParseUint(digits[i:i+1], 16, 8)
+		if err != nil {
+			return 0, 0, errors.New("bad pattern byte " + digits)
+		}
+		value |= byte(d) << shift
+		mask |= 0x0F << shift
+	}
+	return value, mask, nil
+}
+
+// Parses a search pattern like "48 8b ?? ?? 89", "4? 8b", "deadbeef" or
+// "80/c0" (a value and a mask, matching bytes where b&mask == value&mask).
+// Patterns without wildcards or masks give a plain bytesMatcher.
+func ParseSearchPattern(value string) (ZerzMatcher, error) {
+	m := maskMatcher{}
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t'
+	}) {
+		maskDigits := ""
+		if i := strings.IndexByte(field, '/'); i >= 0 {
+			field, maskDigits = field[:i], field[i+1:]
+			if len(maskDigits) != len(field) {
+				return nil, errors.New("value and mask differ in length: " + field + "/" +
+					maskDigits)
+			}
+		}
+		if len(field)%2 != 0 {
+			return nil, errors.New("odd number of hex digits in " + field)
+		}
+		for i := 0; i < len(field); i += 2 {
+			v, mask, err := parsePatternByte(field[i : i+2])
+			if err != nil {
+				return nil, err
+			}
+			if maskDigits != "" {
+				explicit, err := strconv.ParseUint(maskDigits[i:i+2], 16, 8)
+				if err != nil {
+					return nil, errors.New("bad mask byte " + maskDigits[i:i+2])
+				}
+				mask &= byte(explicit)
+			}
+			m.Value = append(m.Value, v)
+			m.Mask = append(m.Mask, mask)
+		}
+	}
+	if len(m.Value) == 0 {
+		return nil, errors.New("no bytes given")
+	}
+	for _, mask := range m.Mask {
+		if mask != 0xFF {
+			return m, nil
+		}
+	}
+	return bytesMatcher(m.Value), nil
+}
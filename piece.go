@@ -0,0 +1,114 @@
+package main
+
+import "sort"
+
+// A run of bytes in the file, taken either from the mmap'd original or from
+// the append-only Added buffer.
+type zerzPiece struct {
+	Added  bool
+	Start  int64
+	Length int64
+}
+
+func (zfile *ZerzFile) resetPieces() {
+	zfile.Pieces = []zerzPiece{{false, 0, zfile.Size}}
+	zfile.Added = nil
+	zfile.updateStarts()
+}
+
+func (zfile *ZerzFile) updateStarts() {
+	zfile.starts = zfile.starts[:0]
+	start := int64(0)
+	for _, piece := range zfile.Pieces {
+		zfile.starts = append(zfile.starts, start)
+		start += piece.Length
+	}
+}
+
+func (zfile *ZerzFile) source(piece zerzPiece) []byte {
+	if piece.Added {
+		return zfile.Added[piece.Start : piece.Start+piece.Length]
+	}
+	return zfile.Bytes[piece.Start : piece.Start+piece.Length]
+}
+
+// Index of the piece containing offset.
+func (zfile *ZerzFile) findPiece(offset int64) int {
+	return sort.Search(len(zfile.starts), func(i int) bool {
+		return zfile.starts[i] > offset
+	}) - 1
+}
+
+func (zfile *ZerzFile) Get(offset int64) byte {
+	i := zfile.findPiece(offset)
+	return zfile.source(zfile.Pieces[i])[offset-zfile.starts[i]]
+}
+
+// Reads up to n bytes from offset; stops at end of file.
+func (zfile *ZerzFile) Read(offset int64, n int64) []byte {
+	if offset+n > zfile.Size {
+		n = zfile.Size - offset
+	}
+	if n <= 0 {
+		return []byte{}
+	}
+	ret := make([]byte, 0, n)
+	i := zfile.findPiece(offset)
+	src := zfile.source(zfile.Pieces[i])[offset-zfile.starts[i]:]
+	for {
+		if int64(len(src)) > n-int64(len(ret)) {
+			src = src[:n-int64(len(ret))]
+		}
+		ret = append(ret, src...)
+		i++
+		if int64(len(ret)) == n {
+			return ret
+		}
+		src = zfile.source(zfile.Pieces[i])
+	}
+}
+
+// Makes sure a piece starts at offset and returns its index, or len(Pieces) if
+// offset is the end of the file.
+func (zfile *ZerzFile) split(offset int64) int {
+	if offset >= zfile.Size {
+		return len(zfile.Pieces)
+	}
+	i := zfile.findPiece(offset)
+	rel := offset - zfile.starts[i]
+	if rel == 0 {
+		return i
+	}
+	piece := zfile.Pieces[i]
+	pieces := make([]zerzPiece, 0, len(zfile.Pieces)+1)
+	pieces = append(pieces, zfile.Pieces[:i]...)
+	pieces = append(pieces, zerzPiece{piece.Added, piece.Start, rel},
+		zerzPiece{piece.Added, piece.Start + rel, piece.Length - rel})
+	pieces = append(pieces, zfile.Pieces[i+1:]...)
+	zfile.Pieces = pieces
+	zfile.updateStarts()
+	return i + 1
+}
+
+// Swaps the n bytes at offset for data, which may be a different length.
+func (zfile *ZerzFile) Replace(offset, n int64, data []byte) {
+	first := zfile.split(offset)
+	last := zfile.split(offset + n)
+	pieces := make([]zerzPiece, 0, len(zfile.Pieces)-(last-first)+1)
+	pieces = append(pieces, zfile.Pieces[:first]...)
+	if len(data) > 0 {
+		end := int64(len(zfile.Added))
+		if first > 0 && pieces[first-1].Added &&
+			pieces[first-1].Start+pieces[first-1].Length == end {
+			// Typing in sequence; just grow the last piece.
+			pieces[first-1].Length += int64(len(data))
+		} else {
+			pieces = append(pieces, zerzPiece{true, end, int64(len(data))})
+		}
+		zfile.Added = append(zfile.Added, data...)
+	}
+	pieces = append(pieces, zfile.Pieces[last:]...)
+	zfile.Pieces = pieces
+	zfile.Size += int64(len(data)) - n
+	zfile.updateStarts()
+}
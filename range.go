@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Gets the range a command should work on: the region if there is one,
+// otherwise whatever the user types, starting at the cursor by default. The
+// range is clipped to the end of the file.
+func (zbuf *ZerzBuffer) GetRange(zed *ZerzEditor, tabbarscroll int) (int64, int64, bool) {
+	start, length, ok := zbuf.Region()
+	if !ok {
+		value := zed.Prompt("range ([start] length)", tabbarscroll)
+		if value == "" {
+			return 0, 0, false
+		}
+		var err error
+		start, length, err = ParseRange(value, zbuf.Offset)
+		if err != nil {
+			zed.Msg("Bad range %q: %s", value, numErr(err))
+			return 0, 0, false
+		} else if start >= zbuf.File.Size {
+			zed.Msg("Range starts past end of file")
+			return 0, 0, false
+		}
+	}
+	if length > zbuf.File.Size-start {
+		length = zbuf.File.Size - start
+		zed.Msg("Range clipped to end of file")
+	}
+	return start, length, true
+}
+
+// Fills the range with pattern, repeated as often as needed.
+func (zbuf *ZerzBuffer) FillRange(start, length int64, pattern []byte) int {
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = pattern[i%len(pattern)]
+	}
+	return zbuf.WriteBytes(start, data)
+}
+
+// Fills the range with a sequence of integers of the current width and
+// endianness, counting up from first by step.
+func (zbuf *ZerzBuffer) FillCount(start, length int64, first, step uint64) int {
+	data := make([]byte, 0, length+8)
+	for value := first; int64(len(data)) < length; value += step {
+		data = append(data, zbuf.encodeInteger(value)...)
+	}
+	return zbuf.WriteBytes(start, data[:length])
+}
+
+func (zbuf *ZerzBuffer) Fill(zed *ZerzEditor, tabbarscroll int) {
+	start, length, ok := zbuf.GetRange(zed, tabbarscroll)
+	if !ok {
+		return
+	}
+	value := zed.Prompt("fill with (hex bytes, or +first [step] to count)", tabbarscroll)
+	if value == "" {
+		return
+	}
+	if value[0] == '+' {
+		fields := strings.Fields(value[1:])
+		if len(fields) == 0 || len(fields) > 2 {
+			zed.Msg("Expected +first [step]")
+			return
+		}
+		first, err := strconv.ParseInt(fields[0], 0, 64)
+		if err != nil {
+			zed.Msg("Bad first value %q: %s", fields[0], numErr(err))
+			return
+		}
+		step := int64(1)
+		if len(fields) == 2 {
+			step, err = strconv.ParseInt(fields[1], 0, 64)
+			if err != nil {
+				zed.Msg("Bad step %q: %s", fields[1], numErr(err))
+				return
+			}
+		}
+		zbuf.FillCount(start, length, uint64(first), uint64(step))
+	} else {
+		pattern, err := ParseHexBytes(value)
+		if err != nil {
+			zed.Msg("Bad fill pattern: %s", err)
+			return
+		}
+		zbuf.FillRange(start, length, pattern)
+	}
+	zbuf.MarkActive = false
+	zed.Msg("Filled %d bytes", length)
+}
+
+// Applies f to each whole element of the current width and endianness in
+// data; a partial element at the end is left alone.
+func (zbuf *ZerzBuffer) mapElements(data []byte, f func(uint64) uint64) []byte {
+	width := 1 << uint(zbuf.IntWidth)
+	ret := make([]byte, len(data))
+	copy(ret, data)
+	for i := 0; i+width <= len(ret); i += width {
+		copy(ret[i:], zbuf.encodeInteger(f(zbuf.interpretBytesAsInteger(ret[i:i+width]))))
+	}
+	return ret
+}
+
+// Works out the transformed contents of data from an operation like "xor de
+// ad", "add 3", "sub 0x10", "rol 1", "ror 4" or "not".
+func (zbuf *ZerzBuffer) TransformBytes(data []byte, value string) ([]byte, error) {
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 2)
+	op := strings.ToLower(fields[0])
+	arg := ""
+	if len(fields) == 2 {
+		arg = fields[1]
+	}
+	bits := uint64(8) << uint(zbuf.IntWidth)
+
+	switch op {
+	case "xor":
+		key, err := ParseHexBytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		ret := make([]byte, len(data))
+		for i := range data {
+			ret[i] = data[i] ^ key[i%len(key)]
+		}
+		return ret, nil
+	case "not":
+		ret := make([]byte, len(data))
+		for i := range data {
+			ret[i] = ^data[i]
+		}
+		return ret, nil
+	case "add", "sub":
+		n, err := strconv.ParseInt(arg, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		if op == "sub" {
+			n = -n
+		}
+		return zbuf.mapElements(data, func(v uint64) uint64 {
+			return v + uint64(n)
+		}), nil
+	case "rol", "ror":
+		n, err := strconv.ParseUint(arg, 0, 8)
+		if err != nil {
+			return nil, err
+		}
+		n %= bits
+		if op == "ror" {
+			n = (bits - n) % bits
+		}
+		return zbuf.mapElements(data, func(v uint64) uint64 {
+			if n == 0 {
+				return v
+			}
+			return v<<n | v>>(bits-n)
+		}), nil
+	}
+	return nil, errors.New("unknown operation " + op)
+}
+
+// Transforms a range, showing the result and asking before writing it.
+func (zbuf *ZerzBuffer) Transform(zed *ZerzEditor, tabbarscroll int) {
+	start, length, ok := zbuf.GetRange(zed, tabbarscroll)
+	if !ok {
+		return
+	}
+	value := zed.Prompt("transform (xor BYTES, add N, sub N, rol N, ror N, not)",
+		tabbarscroll)
+	if value == "" {
+		return
+	}
+	data, err := zbuf.TransformBytes(zbuf.File.Read(start, length), value)
+	if err != nil {
+		zed.Msg("Can't transform: %s", numErr(err))
+		return
+	}
+	zbuf.Preview = []ZerzSpan{{start, data}}
+	apply := zed.YesNo("Apply "+value+"?", tabbarscroll)
+	zbuf.Preview = nil
+	if apply {
+		zbuf.WriteBytes(start, data)
+		zbuf.MarkActive = false
+		zed.Msg("Transformed %d bytes", len(data))
+	}
+}
+
+// Reverses the bytes of each whole element of the current width in data, or
+// with words set, swaps the two halves of each element and leaves the bytes
+// inside them alone. Returns the result and how many bytes at the end didn't
+// make a whole element.
+func (zbuf *ZerzBuffer) SwapBytes(data []byte, words bool) ([]byte, int) {
+	width := 1 << uint(zbuf.IntWidth)
+	ret := make([]byte, len(data))
+	copy(ret, data)
+	for i := 0; i+width <= len(ret); i += width {
+		elem := ret[i : i+width]
+		if words {
+			half := width / 2
+			copy(elem, data[i+half:i+width])
+			copy(elem[half:], data[i:i+half])
+		} else {
+			for j := range elem {
+				elem[j] = data[i+width-1-j]
+			}
+		}
+	}
+	return ret, len(data) % width
+}
+
+func (zbuf *ZerzBuffer) ByteSwap(zed *ZerzEditor, tabbarscroll int) {
+	if zbuf.IntWidth == Int8 {
+		zed.Msg("Nothing to swap in 8-bit elements; widen with L")
+		return
+	}
+	start, length, ok := zbuf.GetRange(zed, tabbarscroll)
+	if !ok {
+		return
+	}
+	bits := 8 << uint(zbuf.IntWidth)
+	value := strings.ToLower(strings.TrimSpace(zed.Prompt(fmt.Sprintf(
+		"swap bytes or %d-bit words in each %d-bit element (b/w)", bits/2, bits),
+		tabbarscroll)))
+	if value != "" && value != "b" && value != "w" {
+		zed.Msg("Expected b or w")
+		return
+	}
+	data, trailing := zbuf.SwapBytes(zbuf.File.Read(start, length), value == "w")
+	zbuf.WriteBytes(start, data)
+	zbuf.MarkActive = false
+	if trailing > 0 {
+		zed.Msg("Swapped %d elements; %d trailing bytes left alone",
+			length/int64(1<<uint(zbuf.IntWidth)), trailing)
+	} else {
+		zed.Msg("Swapped %d elements", length/int64(1<<uint(zbuf.IntWidth)))
+	}
+}
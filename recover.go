@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	termbox "github.com/nsf/termbox-go"
+)
+
+const ZCacheDir = "~/.cache/zerz"
+
+// Every change made to a buffer since it was last saved goes in its journal,
+// one JSON line per change, as it happens. Undoing writes a change the other
+// way round, so replaying the journal in order always gets back to the
+// buffer as it was.
+func journalPath(abspath string) (string, error) {
+	dir, err := homedir.Expand(ZCacheDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, url.PathEscape(abspath)+".journal"), nil
+}
+
+func (zbuf *ZerzBuffer) journal(offset int64, old, new []byte) {
+	if zbuf.journalFailed {
+		return
+	}
+	if zbuf.journalFile == nil {
+		path, err := journalPath(zbuf.File.Filepath)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(path), 0700)
+		}
+		if err == nil {
+			zbuf.journalFile, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		}
+		if err != nil {
+			zbuf.journalFailed, zbuf.journalErr = true, err
+			return
+		}
+	}
+	data, _ := json.Marshal(ZerzUndo{Offset: offset, Old: old, New: new})
+	if _, err := zbuf.journalFile.Write(append(data, '\n')); err != nil {
+		zbuf.journalFailed, zbuf.journalErr = true, err
+	}
+}
+
+// Throws the journal away, once the changes in it are saved or not wanted.
+func (zbuf *ZerzBuffer) DiscardJournal() {
+	if zbuf.journalFile != nil {
+		zbuf.journalFile.Close()
+		zbuf.journalFile = nil
+	}
+	zbuf.journalFailed = false
+	if path, err := journalPath(zbuf.File.Filepath); err == nil {
+		os.Remove(path)
+	}
+}
+
+// Reads back the journal left by a zerz that didn't exit cleanly, if there
+// is one.
+func LoadJournal(abspath string) ([]*ZerzUndo, error) {
+	path, err := journalPath(abspath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Can't read journal: %s", err.Error())
+	}
+	var entries []*ZerzUndo
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		entry := &ZerzUndo{}
+		if json.Unmarshal(scanner.Bytes(), entry) != nil {
+			// Cut off half way through writing it
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Makes the changes in a recovered journal again, as if they'd just been
+// typed, as long as the file still looks like it did when they were made.
+func (zbuf *ZerzBuffer) ReplayJournal() (int, error) {
+	entries := zbuf.Recovered
+	zbuf.Recovered = nil
+	zbuf.DiscardJournal()
+	for i, entry := range entries {
+		if entry.Offset < 0 || entry.Offset > zbuf.File.Size ||
+			!bytes.Equal(zbuf.File.Read(entry.Offset, int64(len(entry.Old))), entry.Old) {
+			return i, fmt.Errorf("Journal doesn't match %s at 0x%x", zbuf.File.Filename,
+				entry.Offset)
+		}
+		zbuf.pushUndo(entry)
+	}
+	zbuf.clampOffset()
+	return len(entries), nil
+}
+
+func (zed *ZerzEditor) RecoverJournals() {
+	for _, buf := range zed.Buffers {
+		if len(buf.Recovered) == 0 {
+			continue
+		}
+		if !zed.YesNo(fmt.Sprintf("%s has %d unsaved changes from a crash; replay them?",
+			buf.File.Filename, len(buf.Recovered)), 0) {
+			buf.Recovered = nil
+			buf.DiscardJournal()
+			zed.Msg("Discarded journal for %s", buf.File.Filename)
+			continue
+		}
+		total := len(buf.Recovered)
+		n, err := buf.ReplayJournal()
+		if err != nil {
+			zed.Msg("%s; replayed %d of %d changes", err.Error(), n, total)
+		} else {
+			zed.Msg("Replayed %d changes to %s", n, buf.File.Filename)
+		}
+	}
+}
+
+func (zed *ZerzEditor) DiscardJournals() {
+	for _, buf := range zed.Buffers {
+		buf.DiscardJournal()
+	}
+}
+
+func (zed *ZerzEditor) reportJournalErrors() {
+	for _, buf := range zed.Buffers {
+		if buf.journalErr != nil {
+			zed.Msg("Can't write journal for %s: %s", buf.File.Filename, buf.journalErr.Error())
+			buf.journalErr = nil
+		}
+	}
+}
+
+// Writes what zerz was doing when it crashed somewhere it can be found
+// afterwards, and returns where that is.
+func (zed *ZerzEditor) dumpState(crash interface{}, stack []byte) (string, error) {
+	dir, err := homedir.Expand(ZCacheDir)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	var dump bytes.Buffer
+	fmt.Fprintf(&dump, "zerz crashed at %s: %v\n\n%s\n", time.Now().Format(time.RFC3339),
+		crash, stack)
+	for i, buf := range zed.Buffers {
+		fmt.Fprintf(&dump, "buffer %d: %s\n\tsize %d offset 0x%x mark 0x%x (active %t)\n"+
+			"\tmode %d width %d big endian %t insert %t\n\t%d changes to undo, %d to redo\n",
+			i+1, buf.File.Filepath, buf.File.Size, buf.Offset, buf.Mark, buf.MarkActive,
+			buf.Mode, buf.IntWidth, buf.BigEndian, buf.Insert, len(buf.UndoList),
+			len(buf.RedoList))
+		if path, err := journalPath(buf.File.Filepath); err == nil && buf.journalFile != nil {
+			fmt.Fprintf(&dump, "\tjournal: %s\n", path)
+		}
+	}
+	path := filepath.Join(dir, "crash-"+time.Now().Format("20060102-150405")+".txt")
+	return path, ioutil.WriteFile(path, dump.Bytes(), 0600)
+}
+
+// Gets the terminal back to normal after a panic, and says where to find out
+// what happened. The journals are left alone for next time.
+func (zed *ZerzEditor) recoverPanic() {
+	crash := recover()
+	if crash == nil {
+		return
+	}
+	stack := debug.Stack()
+	termbox.Close()
+	fmt.Fprintf(os.Stderr, "zerz crashed: %v\n", crash)
+	if path, err := zed.dumpState(crash, stack); err != nil {
+		fmt.Fprintf(os.Stderr, "Can't write state dump: %s\n%s", err.Error(), stack)
+	} else {
+		fmt.Fprintf(os.Stderr, "State dump written to %s\n", path)
+	}
+	fmt.Fprintln(os.Stderr, "Unsaved changes are in the journal; open the files again to recover them.")
+	os.Exit(2)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// A regexp can match any length, but chunks have to overlap by something;
+// matches longer than this across a chunk boundary can be missed.
+const ZRegexOverlap = 4096
+
+// A regular expression over raw bytes. Go's regexp works on UTF-8, so the
+// data is read as Latin-1: byte 0xNN becomes rune U+00NN, and \xNN in the
+// expression matches it.
+type regexMatcher struct {
+	re   *regexp.Regexp
+	expr string
+	// The last data converted, where each of its bytes went, and the
+	// result, since the same chunk gets searched over and over
+	data   []byte
+	starts []int
+	latin1 []byte
+}
+
+func CompileRegex(expr string) (*regexMatcher, error) {
+	// . should match any byte, newline or not
+	re, err := regexp.Compile("(?s)" + expr)
+	if err != nil {
+		return nil, err
+	}
+	return &regexMatcher{re: re, expr: expr}, nil
+}
+
+func (m *regexMatcher) convert(data []byte) {
+	if len(data) > 0 && len(data) == len(m.data) && &data[0] == &m.data[0] {
+		return
+	}
+	m.data = data
+	m.starts = make([]int, 0, len(data)+1)
+	m.latin1 = make([]byte, 0, len(data)*2)
+	for _, b := range data {
+		m.starts = append(m.starts, len(m.latin1))
+		if b < utf8.RuneSelf {
+			m.latin1 = append(m.latin1, b)
+		} else {
+			m.latin1 = append(m.latin1, 0xC0|b>>6, 0x80|b&0x3F)
+		}
+	}
+	m.starts = append(m.starts, len(m.latin1))
+}
+
+func (m *regexMatcher) toData(loc []int) []int {
+	return []int{sort.SearchInts(m.starts, loc[0]), sort.SearchInts(m.starts, loc[1])}
+}
+
+func (m *regexMatcher) Index(data []byte, from int) []int {
+	m.convert(data)
+	loc := m.re.FindIndex(m.latin1[m.starts[from]:])
+	if loc == nil {
+		return nil
+	}
+	return m.toData([]int{loc[0] + m.starts[from], loc[1] + m.starts[from]})
+}
+
+// Matches can overlap, so this steps forward a byte past each one rather than
+// taking FindAll's non-overlapping list.
+func (m *regexMatcher) LastIndex(data []byte, to int) []int {
+	var last []int
+	for from := 0; from < to; {
+		loc := m.Index(data, from)
+		if loc == nil || loc[0] >= to {
+			break
+		}
+		last = loc
+		from = loc[0] + 1
+	}
+	return last
+}
+
+func (m *regexMatcher) MaxLen() int {
+	return ZRegexOverlap
+}
+
+func (m *regexMatcher) String() string {
+	return "/" + m.expr + "/"
+}
+
+func (zed *ZerzEditor) RegexSearch(tabbarscroll, x1, y1, x2, y2 int, backward bool, n int64) {
+	prompt := "regexp search (bytes as Latin-1, \\xNN)"
+	if backward {
+		prompt = "regexp search backward (bytes as Latin-1, \\xNN)"
+	}
+	if zed.LastSearch != nil {
+		prompt += " [" + zed.LastSearch.String() + "]"
+	}
+	value := zed.Prompt(prompt, tabbarscroll)
+	if value != "" {
+		m, err := CompileRegex(value)
+		if err != nil {
+			zed.Msg("Bad regexp: %s", err.Error())
+			return
+		}
+		zed.LastSearch = m
+	} else if zed.LastSearch == nil {
+		return
+	}
+	zed.searchFor(zed.LastSearch, x1, y1, x2, y2, backward, n)
+}
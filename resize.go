@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Chops the file off at the cursor, dropping the byte under it and
+// everything after.
+func (zbuf *ZerzBuffer) Truncate(zed *ZerzEditor, tabbarscroll int) {
+	if zbuf.Offset == 0 {
+		zed.Msg("Can't truncate to an empty file")
+		return
+	}
+	if !zed.YesNo(fmt.Sprintf("Truncate %s to %d (0x%x) bytes?", zbuf.File.Filename,
+		zbuf.Offset, zbuf.Offset), tabbarscroll) {
+		return
+	}
+	zbuf.DeleteBytes(zbuf.Offset, zbuf.File.Size-zbuf.Offset)
+	zed.Msg("Truncated to %d bytes", zbuf.File.Size)
+}
+
+// Works out how many bytes to add from "N" (add N bytes), "=N" (pad to N
+// bytes) or "pow2" (pad to the next power of two).
+func ParseExtend(value string, size int64) (int64, error) {
+	switch {
+	case value == "pow2":
+		target := int64(1)
+		for target < size {
+			if target > math.MaxInt64/2 {
+				return 0, errors.New("value out of range")
+			}
+			target <<= 1
+		}
+		return target - size, nil
+	case strings.HasPrefix(value, "="):
+		target, err := strconv.ParseInt(value[1:], 0, 64)
+		if err != nil {
+			return 0, err
+		} else if target < 0 {
+			return 0, errors.New("value out of range")
+		}
+		return target - size, nil
+	}
+	return strconv.ParseInt(value, 0, 64)
+}
+
+func (zbuf *ZerzBuffer) Extend(zed *ZerzEditor, tabbarscroll int) {
+	value := zed.Prompt("extend by (N, =SIZE or pow2) [fill byte]", tabbarscroll)
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return
+	} else if len(fields) > 2 {
+		zed.Msg("Expected size [fill byte]")
+		return
+	}
+	n, err := ParseExtend(fields[0], zbuf.File.Size)
+	if err != nil {
+		zed.Msg("Bad size %q: %s", fields[0], numErr(err))
+		return
+	} else if n <= 0 {
+		zed.Msg("File is already %d bytes", zbuf.File.Size)
+		return
+	} else if n > ZInsertMax {
+		zed.Msg("Won't extend by more than %d bytes at once", ZInsertMax)
+		return
+	}
+	fill := byte(0)
+	if len(fields) == 2 {
+		result, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil {
+			zed.Msg("Bad fill byte %q: %s", fields[1], numErr(err))
+			return
+		}
+		fill = byte(result)
+	}
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = fill
+	}
+	zbuf.InsertBytes(zbuf.File.Size, data)
+	zed.Msg("Extended by %d bytes to %d", n, zbuf.File.Size)
+}
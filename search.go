@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+)
+
+// Searches read the file this much at a time, so they don't need it all in
+// memory at once.
+const ZSearchChunk = 1 << 20
+
+// Something to search for. Both methods return the start and end of a match
+// in data, or nil. Bytes before from (or at and after to) are only there for
+// context: a match may look at them, but not start there.
+type ZerzMatcher interface {
+	// The first match starting at or after from
+	Index(data []byte, from int) []int
+	// The last match starting before to
+	LastIndex(data []byte, to int) []int
+	// The longest a match can be, so chunks can overlap by that much
+	MaxLen() int
+	String() string
+}
+
+// A plain run of bytes.
+type bytesMatcher []byte
+
+func (m bytesMatcher) Index(data []byte, from int) []int {
+	if i := bytes.Index(data[from:], m); i >= 0 {
+		return []int{from + i, from + i + len(m)}
+	}
+	return nil
+}
+
+func (m bytesMatcher) LastIndex(data []byte, to int) []int {
+	if to+len(m)-1 < len(data) {
+		data = data[:to+len(m)-1]
+	}
+	if i := bytes.LastIndex(data, m); i >= 0 {
+		return []int{i, i + len(m)}
+	}
+	return nil
+}
+
+func (m bytesMatcher) MaxLen() int {
+	return len(m)
+}
+
+func (m bytesMatcher) String() string {
+	return hex.EncodeToString(m)
+}
+
+// Reads the part of the file a search of [start, end) needs to look at: a
+// byte before for context, and enough after for a match starting at the end.
+// Returns it along with where start is in it.
+func (zfile *ZerzFile) searchData(m ZerzMatcher, start, end int64) ([]byte, int64) {
+	ctx := int64(0)
+	if start > 0 {
+		ctx = 1
+	}
+	return zfile.Read(start-ctx, end-start+ctx+int64(m.MaxLen())), ctx
+}
+
+// Returns the start and end of the first match starting in [start, end), or
+// -1, -1.
+func (zfile *ZerzFile) IndexIn(m ZerzMatcher, start, end int64) (int64, int64) {
+	for pos := start; pos < end; pos += ZSearchChunk {
+		chunkEnd := pos + ZSearchChunk
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+		data, ctx := zfile.searchData(m, pos, chunkEnd)
+		if loc := m.Index(data, int(ctx)); loc != nil && pos-ctx+int64(loc[0]) < chunkEnd {
+			return pos - ctx + int64(loc[0]), pos - ctx + int64(loc[1])
+		}
+	}
+	return -1, -1
+}
+
+// Returns the start and end of the last match starting in [start, end), or
+// -1, -1.
+func (zfile *ZerzFile) LastIndexIn(m ZerzMatcher, start, end int64) (int64, int64) {
+	for chunkEnd := end; chunkEnd > start; chunkEnd -= ZSearchChunk {
+		pos := chunkEnd - ZSearchChunk
+		if pos < start {
+			pos = start
+		}
+		data, ctx := zfile.searchData(m, pos, chunkEnd)
+		if loc := m.LastIndex(data, int(chunkEnd-pos+ctx)); loc != nil && int64(loc[0]) >= ctx {
+			return pos - ctx + int64(loc[0]), pos - ctx + int64(loc[1])
+		}
+	}
+	return -1, -1
+}
+
+// Calls f with the start and end of every match starting in [start, end),
+// overlapping ones and all, until it returns false.
+func (zfile *ZerzFile) EachIn(m ZerzMatcher, start, end int64, f func(int64, int64) bool) {
+	for pos := start; pos < end; pos += ZSearchChunk {
+		chunkEnd := pos + ZSearchChunk
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+		data, ctx := zfile.searchData(m, pos, chunkEnd)
+		for from := int(ctx); ; {
+			loc := m.Index(data, from)
+			if loc == nil || pos-ctx+int64(loc[0]) >= chunkEnd {
+				break
+			}
+			if !f(pos-ctx+int64(loc[0]), pos-ctx+int64(loc[1])) {
+				return
+			}
+			from = loc[0] + 1
+		}
+	}
+}
+
+func (zfile *ZerzFile) CountIn(m ZerzMatcher, start, end int64) int64 {
+	count := int64(0)
+	zfile.EachIn(m, start, end, func(int64, int64) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// Finds the next match after from (or before it, going backward), wrapping
+// round the end of the file if need be. Returns its start and end, and
+// whether it wrapped.
+func (zfile *ZerzFile) Find(m ZerzMatcher, from int64, backward bool) (int64, int64, bool) {
+	if backward {
+		if start, end := zfile.LastIndexIn(m, 0, from); start >= 0 {
+			return start, end, false
+		}
+		start, end := zfile.LastIndexIn(m, from, zfile.Size)
+		return start, end, true
+	}
+	if start, end := zfile.IndexIn(m, from+1, zfile.Size); start >= 0 {
+		return start, end, false
+	}
+	start, end := zfile.IndexIn(m, 0, from+1)
+	return start, end, true
+}
+
+// Works out where the matches of Highlight are in the part of the file
+// between start and end, for drawing.
+func (zbuf *ZerzBuffer) findHighlights(start, end int64) {
+	zbuf.highlights = zbuf.highlights[:0]
+	if zbuf.Highlight == nil {
+		return
+	}
+	data, ctx := zbuf.File.searchData(zbuf.Highlight, start, end)
+	for from := int(ctx); ; {
+		loc := zbuf.Highlight.Index(data, from)
+		if loc == nil || start-ctx+int64(loc[0]) >= end {
+			return
+		}
+		zbuf.highlights = append(zbuf.highlights, ZerzSpan{start - ctx + int64(loc[0]),
+			data[loc[0]:loc[1]]})
+		from = loc[1]
+		if loc[1] == loc[0] {
+			from++
+		}
+	}
+}
+
+func (zbuf *ZerzBuffer) InHighlight(offset int64) bool {
+	for _, span := range zbuf.highlights {
+		if span.Offset <= offset && offset < span.Offset+int64(len(span.Data)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Moves the cursor to offset, scrolling it to the top of the view if it
+// isn't already on screen.
+func (zbuf *ZerzBuffer) JumpTo(offset int64, y1, y2 int) {
+	zbuf.Offset = offset
+	line := offset & (math.MaxInt64 - 0x0F)
+	if line < zbuf.Scroll || line > zbuf.Scroll+int64(y2-y1)<<4 {
+		zbuf.Scroll = line
+	}
+}
+
+// Searches for a hex byte sequence n times over, from the cursor. Giving no
+// pattern searches for the last one again.
+func (zed *ZerzEditor) Search(tabbarscroll, x1, y1, x2, y2 int, backward bool, n int64) {
+	prompt := "search hex (?? or 4? wildcards, value/mask)"
+	if backward {
+		prompt = "search hex backward (?? or 4? wildcards, value/mask)"
+	}
+	if zed.LastSearch != nil {
+		prompt += " [" + zed.LastSearch.String() + "]"
+	}
+	value := zed.Prompt(prompt, tabbarscroll)
+	if value != "" {
+		pattern, err := ParseSearchPattern(value)
+		if err != nil {
+			zed.Msg("Bad search pattern: %s", err.Error())
+			return
+		}
+		zed.LastSearch = pattern
+	} else if zed.LastSearch == nil {
+		return
+	}
+	zed.searchFor(zed.LastSearch, x1, y1, x2, y2, backward, n)
+}
+
+// Moves the cursor to the nth match of m from it.
+func (zed *ZerzEditor) searchFor(m ZerzMatcher, x1, y1, x2, y2 int, backward bool, n int64) {
+	buf := zed.FocusBuf()
+	_, _, yy1, _, yy2 := zed.Tree.GetFocusBufDimensions(x1, y1, x2, y2)
+	at, end, wrapped := buf.Offset, int64(0), false
+	first := int64(-1)
+	n = clampCount(n, ZRepeatMax)
+	for i := int64(0); i < n; i++ {
+		next, nextEnd, wrap := buf.File.Find(m, at, backward)
+		if next < 0 {
+			zed.Msg("Not found: %s", m.String())
+			return
+		}
+		if i == 0 {
+			first = next
+		} else if next == first {
+			// Back where we started, so skip the rest of the whole laps
+			n = i + 1 + (n-i-1)%i
+		}
+		at, end, wrapped = next, nextEnd, wrapped || wrap
+	}
+	buf.JumpTo(at, yy1, yy2)
+	buf.Highlight = m
+	count := zed.countMatches(m)
+	if wrapped {
+		zed.Msg("Wrapped search; found %d bytes at 0x%x (%d matches)", end-at, at, count)
+	} else {
+		zed.Msg("Found %d bytes at 0x%x (%d matches)", end-at, at, count)
+	}
+}
+
+// What was counted last time, so stepping through matches doesn't read the
+// whole file every time.
+type zerzCount struct {
+	buf     *ZerzBuffer
+	pattern string
+	undo    int
+	top     *ZerzUndo
+	n       int64
+}
+
+func (zed *ZerzEditor) countMatches(m ZerzMatcher) int64 {
+	buf := zed.FocusBuf()
+	var top *ZerzUndo
+	if len(buf.UndoList) > 0 {
+		top = buf.UndoList[len(buf.UndoList)-1]
+	}
+	key := zerzCount{buf: buf, pattern: m.String(), undo: len(buf.UndoList), top: top}
+	key.n = zed.lastCount.n
+	if key != zed.lastCount {
+		key.n = buf.File.CountIn(m, 0, buf.File.Size)
+		zed.lastCount = key
+	}
+	return key.n
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+type ZerzStrEnc uint8
+type ZerzStrTerm uint8
+
+const (
+	EncUTF8 ZerzStrEnc = iota
+	EncASCII
+	EncUTF16LE
+	EncUTF16BE
+)
+
+const (
+	TermNone ZerzStrTerm = iota
+	TermNul
+	TermPascal
+	TermPad
+)
+
+var strEncNames = []string{"utf8", "ascii", "utf16le", "utf16be"}
+var strTermNames = []string{"none", "nul", "pascal", "pad"}
+
+func (zbuf *ZerzBuffer) StrOptStr() string {
+	if zbuf.StrTerm == TermPad {
+		return fmt.Sprintf("%s, pad %d", strEncNames[zbuf.StrEnc], zbuf.StrPad)
+	}
+	return strEncNames[zbuf.StrEnc] + ", " + strTermNames[zbuf.StrTerm]
+}
+
+// Encodes a string the way ModeChar writes it: in the buffer's encoding,
+// with its terminator, length prefix or padding.
+func (zbuf *ZerzBuffer) EncodeString(value string) ([]byte, error) {
+	var data []byte
+	unit := 1
+	switch zbuf.StrEnc {
+	case EncASCII:
+		for _, r := range value {
+			if r > 0x7f {
+				return nil, fmt.Errorf("%q isn't ASCII", r)
+			}
+		}
+		data = []byte(value)
+	case EncUTF8:
+		data = []byte(value)
+	case EncUTF16LE, EncUTF16BE:
+		unit = 2
+		for _, u := range utf16.Encode([]rune(value)) {
+			if zbuf.StrEnc == EncUTF16BE {
+				data = append(data, byte(u>>8), byte(u))
+			} else {
+				data = append(data, byte(u), byte(u>>8))
+			}
+		}
+	}
+
+	switch zbuf.StrTerm {
+	case TermNul:
+		data = append(data, make([]byte, unit)...)
+	case TermPascal:
+		length := uint64(len(data) / unit)
+		bits := uint(8) << uint(zbuf.IntWidth)
+		if bits < 64 && length >= 1<<bits {
+			return nil, fmt.Errorf("string too long for a %d-bit length", bits)
+		}
+		data = append(zbuf.encodeInteger(length), data...)
+	case TermPad:
+		if int64(len(data)) > zbuf.StrPad {
+			return nil, fmt.Errorf("string doesn't fit in %d bytes", zbuf.StrPad)
+		}
+		data = append(data, make([]byte, zbuf.StrPad-int64(len(data)))...)
+	}
+	return data, nil
+}
+
+// Sets string options from something like "utf16le nul" or "ascii pad 16";
+// anything not mentioned stays as it was.
+func (zbuf *ZerzBuffer) ParseStrOpts(value string) error {
+	fields := strings.Fields(strings.ToLower(value))
+	enc, term, pad := zbuf.StrEnc, zbuf.StrTerm, zbuf.StrPad
+	for i := 0; i < len(fields); i++ {
+		found := false
+		for j, name := range strEncNames {
+			if fields[i] == name {
+				enc, found = ZerzStrEnc(j), true
+			}
+		}
+		for j, name := range strTermNames {
+			if fields[i] == name {
+				term, found = ZerzStrTerm(j), true
+			}
+		}
+		if !found {
+			return errors.New("unknown string option " + fields[i])
+		}
+		if term == TermPad && fields[i] == "pad" {
+			if i+1 == len(fields) {
+				return errors.New("pad needs a width")
+			}
+			i++
+			width, err := strconv.ParseInt(fields[i], 0, 64)
+			if err != nil || width <= 0 {
+				return errors.New("bad pad width " + fields[i])
+			} else if width > ZInsertMax {
+				return fmt.Errorf("pad width can't be more than %d", ZInsertMax)
+			}
+			pad = width
+		}
+	}
+	zbuf.StrEnc, zbuf.StrTerm, zbuf.StrPad = enc, term, pad
+	return nil
+}
+
+func (zbuf *ZerzBuffer) StrOpts(zed *ZerzEditor, tabbarscroll int) {
+	value := zed.Prompt("string options ("+strings.Join(strEncNames, "|")+", "+
+		strings.Join(strTermNames, "|")+" N) now "+zbuf.StrOptStr(), tabbarscroll)
+	if value == "" {
+		return
+	}
+	if err := zbuf.ParseStrOpts(value); err != nil {
+		zed.Error(err)
+	} else {
+		zed.Msg("Strings are now %s", zbuf.StrOptStr())
+	}
+}
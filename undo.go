@@ -0,0 +1,174 @@
+package main
+
+// A single change to a buffer: the bytes Old at Offset were replaced by New.
+// Overwrites have both the same length; inserts have no Old and deletes have
+// no New. Undoing puts Old back, redoing puts New back.
+//
+// An entry with a Group is several changes that get undone together; it has
+// no bytes of its own and its Offset is just where the cursor goes.
+type ZerzUndo struct {
+	Offset int64
+	Old    []byte
+	New    []byte
+	Group  []*ZerzUndo `json:",omitempty"`
+}
+
+func (zbuf *ZerzBuffer) pushUndo(entry *ZerzUndo) {
+	zbuf.File.Replace(entry.Offset, int64(len(entry.Old)), entry.New)
+	zbuf.journal(entry.Offset, entry.Old, entry.New)
+	if len(zbuf.UndoList) < zbuf.SavedUndo {
+		// The saved state was in the redo list, which is about to go.
+		zbuf.SavedUndo = -1
+	}
+	zbuf.UndoList = append(zbuf.UndoList, entry)
+	zbuf.RedoList = nil
+}
+
+// Runs f and turns whatever changes it makes into a single undo entry.
+func (zbuf *ZerzBuffer) Grouped(f func()) {
+	mark := len(zbuf.UndoList)
+	f()
+	if len(zbuf.UndoList)-mark < 2 {
+		return
+	}
+	group := make([]*ZerzUndo, len(zbuf.UndoList)-mark)
+	copy(group, zbuf.UndoList[mark:])
+	zbuf.UndoList = append(zbuf.UndoList[:mark],
+		&ZerzUndo{Offset: group[0].Offset, Group: group})
+}
+
+func (zbuf *ZerzBuffer) unmake(entry *ZerzUndo) {
+	for i := len(entry.Group) - 1; i >= 0; i-- {
+		zbuf.unmake(entry.Group[i])
+	}
+	if entry.Group == nil {
+		zbuf.File.Replace(entry.Offset, int64(len(entry.New)), entry.Old)
+		zbuf.journal(entry.Offset, entry.New, entry.Old)
+	}
+}
+
+func (zbuf *ZerzBuffer) remake(entry *ZerzUndo) {
+	for _, part := range entry.Group {
+		zbuf.remake(part)
+	}
+	if entry.Group == nil {
+		zbuf.File.Replace(entry.Offset, int64(len(entry.Old)), entry.New)
+		zbuf.journal(entry.Offset, entry.Old, entry.New)
+	}
+}
+
+// Writes data at offset, clipping at the end of the file, and records the
+// change so it can be undone. Returns the number of bytes actually written.
+func (zbuf *ZerzBuffer) WriteBytes(offset int64, data []byte) int {
+	if offset < 0 || offset >= zbuf.File.Size {
+		return 0
+	}
+	if offset+int64(len(data)) > zbuf.File.Size {
+		data = data[:zbuf.File.Size-offset]
+	}
+	if len(data) == 0 {
+		return 0
+	}
+	entry := &ZerzUndo{Offset: offset, Old: zbuf.File.Read(offset, int64(len(data))),
+		New: make([]byte, len(data))}
+	copy(entry.New, data)
+	zbuf.pushUndo(entry)
+	return len(data)
+}
+
+// Replaces the n bytes at offset with data, which may be a different length.
+func (zbuf *ZerzBuffer) ReplaceBytes(offset, n int64, data []byte) int {
+	if offset < 0 || offset > zbuf.File.Size || n < 0 {
+		return 0
+	}
+	if offset+n > zbuf.File.Size {
+		n = zbuf.File.Size - offset
+	}
+	if len(data) == 0 && (n == 0 || n >= zbuf.File.Size) {
+		return 0
+	}
+	entry := &ZerzUndo{Offset: offset, Old: zbuf.File.Read(offset, n),
+		New: make([]byte, len(data))}
+	copy(entry.New, data)
+	zbuf.pushUndo(entry)
+	zbuf.clampOffset()
+	return len(data)
+}
+
+// Inserts data before offset, growing the file.
+func (zbuf *ZerzBuffer) InsertBytes(offset int64, data []byte) int {
+	if offset < 0 || offset > zbuf.File.Size || len(data) == 0 {
+		return 0
+	}
+	entry := &ZerzUndo{Offset: offset, New: make([]byte, len(data))}
+	copy(entry.New, data)
+	zbuf.pushUndo(entry)
+	return len(data)
+}
+
+// Deletes up to n bytes from offset, shrinking the file. The last byte of a
+// file can't be deleted, since there'd be nothing to put the cursor on.
+func (zbuf *ZerzBuffer) DeleteBytes(offset, n int64) int {
+	if offset < 0 || offset >= zbuf.File.Size || n <= 0 {
+		return 0
+	}
+	if offset+n > zbuf.File.Size {
+		n = zbuf.File.Size - offset
+	}
+	if n >= zbuf.File.Size {
+		return 0
+	}
+	zbuf.pushUndo(&ZerzUndo{Offset: offset, Old: zbuf.File.Read(offset, n)})
+	zbuf.clampOffset()
+	return int(n)
+}
+
+// Writes or inserts data at the cursor, depending on the insert state.
+func (zbuf *ZerzBuffer) PutBytes(data []byte) int {
+	if zbuf.Insert {
+		return zbuf.InsertBytes(zbuf.Offset, data)
+	}
+	return zbuf.WriteBytes(zbuf.Offset, data)
+}
+
+func (zbuf *ZerzBuffer) DeleteForward(n int64) {
+	zbuf.DeleteBytes(zbuf.Offset, n)
+}
+
+func (zbuf *ZerzBuffer) DeleteBackward(n int64) {
+	n = clampCount(n, zbuf.Offset)
+	if n > 0 && zbuf.DeleteBytes(zbuf.Offset-n, n) > 0 {
+		zbuf.Offset -= n
+	}
+}
+
+// Undoes the last change, returning false if there was nothing to undo.
+func (zbuf *ZerzBuffer) Undo() bool {
+	if len(zbuf.UndoList) == 0 {
+		return false
+	}
+	entry := zbuf.UndoList[len(zbuf.UndoList)-1]
+	zbuf.UndoList = zbuf.UndoList[:len(zbuf.UndoList)-1]
+	zbuf.unmake(entry)
+	zbuf.RedoList = append(zbuf.RedoList, entry)
+	zbuf.Offset = entry.Offset
+	zbuf.clampOffset()
+	return true
+}
+
+func (zbuf *ZerzBuffer) Redo() bool {
+	if len(zbuf.RedoList) == 0 {
+		return false
+	}
+	entry := zbuf.RedoList[len(zbuf.RedoList)-1]
+	zbuf.RedoList = zbuf.RedoList[:len(zbuf.RedoList)-1]
+	zbuf.remake(entry)
+	zbuf.UndoList = append(zbuf.UndoList, entry)
+	zbuf.Offset = entry.Offset
+	zbuf.clampOffset()
+	return true
+}
+
+func (zbuf *ZerzBuffer) Modified() bool {
+	return len(zbuf.UndoList) != zbuf.SavedUndo
+}
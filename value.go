@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Listing more hits than this isn't much use to anyone.
+const ZValueHitsMax = 1000
+
+// One way a value can be written: its bytes, and how they were encoded.
+type zerzEncoding struct {
+	Data []byte
+	Name string
+}
+
+type zerzValueHit struct {
+	Offset int64
+	Name   string
+}
+
+// Encodes value at every width in both byte orders, as signed or unsigned
+// according to the mode. Widths it doesn't fit in are left out, and so are
+// encodings that come out the same as another.
+func (zbuf *ZerzBuffer) ValueEncodings(value string) []zerzEncoding {
+	sign := "uint"
+	if zbuf.Mode == ModeInt {
+		sign = "int"
+	}
+	// A copy to encode with, so the buffer's own settings stay put
+	view := *zbuf
+	ret := []zerzEncoding{}
+	for width := Int8; width <= Int64; width++ {
+		for _, big := range []bool{false, true} {
+			view.IntWidth, view.BigEndian = width, big
+			data, err := view.EncodeValue(value)
+			if err != nil {
+				continue
+			}
+			dup := false
+			for _, enc := range ret {
+				dup = dup || bytes.Equal(enc.Data, data)
+			}
+			if dup {
+				continue
+			}
+			name := fmt.Sprintf("%s%d", sign, 8<<uint(width))
+			if width > Int8 {
+				name += " " + view.EndStr()
+			}
+			ret = append(ret, zerzEncoding{data, name})
+		}
+	}
+	return ret
+}
+
+// Searches for a number encoded the way Edit would write it. With "all"
+// after it, looks for it at every width in both byte orders and lists what
+// it found.
+func (zed *ZerzEditor) ValueSearch(tabbarscroll, x1, y1, x2, y2 int, n int64) {
+	buf := zed.FocusBuf()
+	value := strings.TrimSpace(zed.Prompt("search value (add \"all\" for every width and order)",
+		tabbarscroll))
+	if value == "" {
+		return
+	}
+	fields := strings.Fields(value)
+	if len(fields) != 2 || fields[1] != "all" {
+		data, err := buf.EncodeValue(value)
+		if err != nil {
+			zed.Msg("Bad value %q: %s", value, numErr(err))
+			return
+		}
+		zed.LastSearch = bytesMatcher(data)
+		zed.searchFor(zed.LastSearch, x1, y1, x2, y2, false, n)
+		return
+	}
+
+	if buf.Mode != ModeInt && buf.Mode != ModeUInt {
+		zed.Msg("Searching all widths needs INT or UINT mode")
+		return
+	}
+	encodings := buf.ValueEncodings(fields[0])
+	if len(encodings) == 0 {
+		zed.Msg("Bad value %q", fields[0])
+		return
+	}
+	hits := []zerzValueHit{}
+	for _, enc := range encodings {
+		if len(hits) >= ZValueHitsMax {
+			break
+		}
+		buf.File.EachIn(bytesMatcher(enc.Data), 0, buf.File.Size, func(start, end int64) bool {
+			hits = append(hits, zerzValueHit{start, enc.Name})
+			return len(hits) < ZValueHitsMax
+		})
+	}
+	times := fmt.Sprintf("%d times", len(hits))
+	if len(hits) >= ZValueHitsMax {
+		times = "at least " + times
+	}
+	if len(hits) == 0 {
+		zed.Msg("Not found: %s at any width", fields[0])
+		return
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Offset < hits[j].Offset
+	})
+	lines := make([]string, len(hits))
+	for i, hit := range hits {
+		lines[i] = fmt.Sprintf("%016x: %s", hit.Offset, hit.Name)
+	}
+	infoBox("Zerz", fmt.Sprintf("%s found %s:", fields[0], times), lines)
+
+	// Go to the first hit after the cursor, or the first one if none are
+	at := hits[0].Offset
+	for _, hit := range hits {
+		if hit.Offset > buf.Offset {
+			at = hit.Offset
+			break
+		}
+	}
+	_, _, yy1, _, yy2 := zed.Tree.GetFocusBufDimensions(x1, y1, x2, y2)
+	buf.JumpTo(at, yy1, yy2)
+	zed.Msg("%s found %s; went to 0x%x", fields[0], times, at)
+}
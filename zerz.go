@@ -1,6 +1,9 @@
 package main
 
 import (
+	"strconv"
+	"strings"
+
 	termutil "github.com/japanoise/termbox-util"
 	termbox "github.com/nsf/termbox-go"
 )
@@ -16,6 +19,12 @@ const (
 	ZCursorInt                       = termbox.ColorMagenta
 	ZCursorUInt                      = termbox.ColorYellow
 	ZCursorFg                        = termbox.ColorBlack
+	ZRegionBg                        = termbox.ColorCyan
+	ZRegionFg                        = termbox.ColorBlack
+	ZPreviewBg                       = termbox.ColorRed
+	ZPreviewFg                       = termbox.ColorBlack
+	ZMatchBg                         = termbox.ColorWhite
+	ZMatchFg                         = termbox.ColorBlack
 	ZStatBg                          = ZBgColor
 	ZStatFg                          = termbox.AttrReverse
 	ZFlagColorL                      = termbox.ColorGreen
@@ -68,18 +77,33 @@ func infoBox(title, prompt string, messages []string) {
 		lens = append(lens, len(message))
 	}
 
+	scroll := 0
 	for !done {
+		// Rows 4 to sy-3 are inside the box
+		rows := sy - 6
+		if scroll > len(messages)-rows {
+			scroll = len(messages) - rows
+		}
+		if scroll < 0 {
+			scroll = 0
+		}
 		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
 		box(1, 1, sx-2, sy-2)
 		termutil.PrintStringFgBg(2, 1, title, ZFgColor, ZBgColor)
 		termutil.PrintStringFgBg(2, 2, prompt, ZFgColor, ZBgColor)
-		for i, message := range messages {
+		for i := scroll; i < len(messages) && i-scroll < rows; i++ {
 			if lens[i] > sx-4 {
-				termutil.PrintStringFgBg(2, 4+i, message[:sx-4], ZFgColor, ZBgColor)
+				termutil.PrintStringFgBg(2, 4+i-scroll, messages[i][:sx-4], ZFgColor, ZBgColor)
 			} else {
-				termutil.PrintStringFgBg(2, 4+i, message, ZFgColor, ZBgColor)
+				termutil.PrintStringFgBg(2, 4+i-scroll, messages[i], ZFgColor, ZBgColor)
 			}
 		}
+		if scroll > 0 {
+			termbox.SetCell(sx-3, 3, '↑', ZFgColor, ZBgColor)
+		}
+		if scroll+rows < len(messages) {
+			termbox.SetCell(sx-3, sy-2, '↓', ZFgColor, ZBgColor)
+		}
 		termbox.Flush()
 
 		ev := termbox.PollEvent()
@@ -88,10 +112,19 @@ func infoBox(title, prompt string, messages []string) {
 			termbox.Sync()
 			sx, sy = termbox.Size()
 		case termbox.EventKey:
-			if ev.Key == termbox.KeyEnter || ev.Key ==
+			switch {
+			case ev.Key == termbox.KeyEnter || ev.Key ==
 				termbox.KeyCtrlC || ev.Key == termbox.KeyCtrlG ||
-				ev.Ch|0x20 == 'q' {
+				ev.Ch|0x20 == 'q':
 				done = true
+			case ev.Key == termbox.KeyArrowDown || ev.Key == termbox.KeyCtrlN:
+				scroll++
+			case ev.Key == termbox.KeyArrowUp || ev.Key == termbox.KeyCtrlP:
+				scroll--
+			case ev.Key == termbox.KeyPgdn || ev.Key == termbox.KeyCtrlV || ev.Key == termbox.KeySpace:
+				scroll += rows
+			case ev.Key == termbox.KeyPgup || (ev.Mod == termbox.ModAlt && ev.Ch == 'v'):
+				scroll -= rows
 			}
 		}
 	}
@@ -105,166 +138,457 @@ func showErrorList(title, prompt string, errors []error) {
 	infoBox(title, prompt, messages)
 }
 
-func zerz(global *ZerzEditor, startupErrors []error) {
-	initTerm()
-	defer termbox.Close()
-	if len(startupErrors) > 0 {
-		showErrorList("Zerz", "Some files had errors:", startupErrors)
+// State of the main loop that has to last between events, so that events
+// can be fed through it from somewhere other than the terminal.
+type zerzLoop struct {
+	global       *ZerzEditor
+	sx, sy       int
+	tabbarscroll int
+	ctlx         bool
+	done         bool
+	hasArg       bool
+	argDigits    string
+	argTyping    bool
+	argTimes     int64
+	isearch      *zerzISearch
+}
+
+func (loop *zerzLoop) sync() {
+	termbox.Sync()
+	loop.sx, loop.sy = termbox.Size()
+}
+
+func (loop *zerzLoop) draw() {
+	loop.global.Draw(loop.tabbarscroll, loop.sx, loop.sy, 0, 2, loop.sx, loop.sy-2)
+	pending := ""
+	if loop.hasArg {
+		pending = "C-u " + loop.argStr() + "-"
 	}
-	done := false
-	sx, sy := termbox.Size()
-	tabbarscroll := 0
-	for !done {
-		bufareatop, bufareabot := 2, sy-2
+	if loop.ctlx {
+		pending += " C-x-"
+	}
+	if pending != "" {
+		termutil.PrintStringFgBg(loop.sx-len(pending)-1, loop.sy-1, pending, ZStatFg, ZStatBg)
+	}
+	if loop.isearch != nil {
+		loop.drawISearch()
+	}
+	termbox.Flush()
+}
 
-		global.Draw(tabbarscroll, sx, sy, 0, bufareatop, sx, bufareabot)
-		termbox.Flush()
+func (loop *zerzLoop) argStr() string {
+	if loop.argDigits == "" || loop.argDigits == "0x" {
+		return strconv.FormatInt(loop.argTimes, 10)
+	}
+	return loop.argDigits
+}
 
-		event := termbox.PollEvent()
-		if event.Type == termbox.EventResize {
-			termbox.Sync()
-			sx, sy = termbox.Size()
-		} else if event.Type == termbox.EventKey {
-			if event.Ch == 0 {
+// Handles the keys that build up a numeric argument: C-u on its own
+// multiplies by four, and digits typed after it (or with M-) give a number,
+// which can be hex if it starts with 0x. Returns true if the event was eaten.
+func (loop *zerzLoop) prefixArg(event termbox.Event) bool {
+	if event.Type != termbox.EventKey || loop.ctlx {
+		return false
+	}
+	if event.Ch == 0 && event.Key == termbox.KeyCtrlU {
+		if !loop.hasArg {
+			loop.hasArg, loop.argTimes, loop.argDigits = true, 1, ""
+		}
+		if loop.argDigits == "" {
+			loop.argTimes *= 4
+		}
+		loop.argTyping = true
+		return true
+	}
+	digit := event.Ch != 0 && ((event.Mod == termbox.ModAlt && '0' <= event.Ch && event.Ch <= '9') ||
+		(loop.argTyping && event.Mod == 0 && ('0' <= event.Ch && event.Ch <= '9' ||
+			(event.Ch == 'x' && loop.argDigits == "0") ||
+			(strings.HasPrefix(loop.argDigits, "0x") && strings.ContainsRune("abcdefABCDEF", event.Ch)))))
+	if !digit {
+		return false
+	}
+	next := loop.argDigits + string(event.Ch)
+	if _, err := parseArg(next); err != nil && next != "0x" {
+		// Would overflow; ignore it
+		return true
+	}
+	loop.hasArg, loop.argTyping = true, true
+	loop.argDigits = next
+	return true
+}
+
+// Digits typed as an argument are decimal, even with leading zeroes, unless
+// they start with 0x.
+func parseArg(digits string) (int64, error) {
+	if strings.HasPrefix(digits, "0x") {
+		return strconv.ParseInt(digits[2:], 16, 64)
+	}
+	return strconv.ParseInt(digits, 10, 64)
+}
+
+// Returns the numeric argument for the command about to run (1 if there
+// isn't one) and clears it.
+func (loop *zerzLoop) takeArg() int64 {
+	n := int64(1)
+	if loop.hasArg {
+		n = loop.argTimes
+		if loop.argDigits != "" && loop.argDigits != "0x" {
+			n, _ = parseArg(loop.argDigits)
+		}
+	}
+	loop.hasArg, loop.argTyping, loop.argDigits = false, false, ""
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// Most times a single command gets repeated by a numeric argument, and the
+// most splits it'll make; past a handful the windows are too small to use.
+const (
+	ZRepeatMax = 1 << 16
+	ZSplitMax  = 8
+)
+
+// Runs f up to n times, stopping early once it reports it did nothing.
+func repeat(n int64, f func() bool) {
+	n = clampCount(n, ZRepeatMax)
+	for i := int64(0); i < n; i++ {
+		if !f() {
+			return
+		}
+	}
+}
+
+// Wraps a command that always works so it can be repeated.
+func always(f func()) func() bool {
+	return func() bool {
+		f()
+		return true
+	}
+}
+
+func (loop *zerzLoop) handleEvent(event termbox.Event) {
+	global := loop.global
+	bufareatop, bufareabot := 2, loop.sy-2
+	if loop.isearch != nil && loop.isearchEvent(event) {
+		global.DoScroll(0, bufareatop, loop.sx, bufareabot)
+		return
+	}
+	if loop.prefixArg(event) {
+		return
+	}
+	n := int64(1)
+	if event.Type == termbox.EventKey && (loop.ctlx || event.Ch != 0 || event.Key != termbox.KeyCtrlX) {
+		// C-x is a prefix too, so the argument waits for the key after it
+		n = loop.takeArg()
+	}
+	if event.Type == termbox.EventResize {
+		loop.sync()
+	} else if event.Type == termbox.EventKey {
+		if loop.ctlx {
+			loop.ctlx = false
+			if '0' <= event.Ch && event.Ch <= '9' {
+				if event.Ch == '0' {
+					repeat(n, global.KillSplit)
+				} else if int(event.Ch-'1') < len(global.Buffers) {
+					global.SwitchBuf(int(event.Ch - '1'))
+				}
+			} else if event.Ch == 0 {
 				switch event.Key {
-				case termbox.KeyCtrlC:
-					done = true
-				case termbox.KeyCtrlF, termbox.KeyArrowRight:
-					if event.Mod == termbox.ModAlt {
-						global.FocusBuf().ForwardDWord()
-					} else {
-						global.FocusBuf().ForwardByte()
-					}
-				case termbox.KeyCtrlN, termbox.KeyArrowDown:
-					global.FocusBuf().ForwardParagraph()
-				case termbox.KeyCtrlB, termbox.KeyArrowLeft:
-					if event.Mod == termbox.ModAlt {
-						global.FocusBuf().BackwardDWord()
-					} else {
-						global.FocusBuf().BackwardByte()
+				case termbox.KeyCtrlS:
+					if err := global.SaveFocusBuf(); err != nil {
+						global.Error(err)
 					}
-				case termbox.KeyCtrlP, termbox.KeyArrowUp:
-					global.FocusBuf().BackwardParagraph()
-				case termbox.KeyPgup:
-					global.PageUp(0, bufareatop, sx, bufareabot)
-				case termbox.KeyCtrlV, termbox.KeyPgdn:
-					global.PageDown(0, bufareatop, sx, bufareabot)
-				case termbox.KeyCtrlA, termbox.KeyHome:
-					global.FocusBuf().StartOfLine()
-				case termbox.KeyCtrlE, termbox.KeyEnd:
-					global.FocusBuf().EndOfLine()
-				case termbox.KeyF1:
-					helpscreen()
-					termbox.Sync()
-					sx, sy = termbox.Size()
-				case termbox.KeyEnter:
-					global.FocusBuf().Edit(global, tabbarscroll)
-					termbox.Sync()
-					sx, sy = termbox.Size()
-				}
-			} else if event.Mod == termbox.ModAlt {
-				if '1' <= event.Ch && event.Ch <= '9' && int(event.Ch-'1') < len(global.Buffers) {
-					global.SwitchBuf(int(event.Ch - '1'))
+				case termbox.KeyCtrlR:
+					global.RevertFocusBuf(loop.tabbarscroll)
+				case termbox.KeyCtrlX:
+					global.FocusBuf().ExchangePointAndMark()
 				}
+			} else {
 				switch event.Ch {
-				case '<':
-					global.FocusBuf().StartOfFile()
-				case '>':
-					global.FocusBuf().EndOfFile()
-				case 'v':
-					global.PageUp(0, bufareatop, sx, bufareabot)
+				case 'w':
+					global.SetKillFill(loop.tabbarscroll)
 				case 'f':
-					global.FocusBuf().ForwardWord()
-				case 'b':
-					global.FocusBuf().BackwardWord()
+					global.FocusBuf().Fill(global, loop.tabbarscroll)
+				case 'x':
+					global.FocusBuf().Transform(global, loop.tabbarscroll)
+				case 'p':
+					global.FocusBuf().WriteNotation(global, loop.tabbarscroll)
+				case 't':
+					global.FocusBuf().Truncate(global, loop.tabbarscroll)
 				case 'g':
-					global.FocusBuf().GoTo(global, tabbarscroll)
-					termbox.Sync()
-					sx, sy = termbox.Size()
-				case '-', '_':
-					global.VSplit()
-				case '|':
-					global.HSplit()
-				case 'u', 'U':
-					global.SplitUp()
-				case 'd', 'D':
-					global.SplitDown()
-				case 'l', 'L':
-					global.SplitLeft()
-				case 'r', 'R':
-					global.SplitRight()
-				case '0':
-					global.KillSplit()
-				}
-			} else {
-				switch event.Ch {
-				case 'H':
-					if Int8 < global.FocusBuf().IntWidth {
-						global.FocusBuf().IntWidth--
+					global.FocusBuf().Extend(global, loop.tabbarscroll)
+				case 's':
+					global.FocusBuf().StrOpts(global, loop.tabbarscroll)
+				case 'b':
+					global.FocusBuf().ByteSwap(global, loop.tabbarscroll)
+				case 'c':
+					global.FocusBuf().CopyAs(global, loop.tabbarscroll)
+				case 'v':
+					global.FocusBuf().MoveBlock(global, loop.tabbarscroll)
+				case 'V':
+					global.FocusBuf().SwapBlocks(global, loop.tabbarscroll)
+				case 'm':
+					global.ShowMessages()
+				case '(':
+					global.StartMacro()
+				case ')':
+					global.EndMacro()
+				case 'e':
+					if global.LastMacro == nil {
+						global.Msg("No macro defined")
 					}
-				case 'L':
-					if global.FocusBuf().IntWidth < Int64 {
-						global.FocusBuf().IntWidth++
+					loop.playMacro(global.LastMacro, int(n))
+				case 'E':
+					loop.RepeatLastMacro()
+				case 'n':
+					if err := global.NameMacro(loop.tabbarscroll); err != nil {
+						global.Error(err)
+					}
+				case 'r':
+					if err := loop.PlayNamedMacro(); err != nil {
+						global.Error(err)
 					}
-				case 'A':
-					// alt+arrows give ABCD on my term, and zerz is modal, so why not :)
-					global.SplitUp()
-				case 'B':
-					global.SplitDown()
-				case 'D':
-					global.SplitLeft()
-				case 'C':
-					global.SplitRight()
-				case 'c':
-					global.FocusBuf().Mode = ModeChar
-				case 'p', 'P':
-					global.FocusBuf().Mode = ModePattern
-				case 'i', 'I':
-					global.FocusBuf().Mode = ModeInt
-				case 'u', 'U':
-					global.FocusBuf().Mode = ModeUInt
-				case 'e', 'E':
-					global.FocusBuf().BigEndian = !global.FocusBuf().BigEndian
-				case '?':
-					helpscreen()
-					termbox.Sync()
-					sx, sy = termbox.Size()
-				case 'q', 'Q':
-					done = true
 				}
 			}
-			global.DoScroll(0, bufareatop, sx, bufareabot)
-		} else if event.Type == termbox.EventMouse {
-			if event.MouseY < 1 {
-				if (event.Key == termbox.MouseWheelUp || (event.Key == termbox.MouseLeft &&
-					event.MouseX == 0)) && tabbarscroll > 0 {
-					tabbarscroll--
-				} else if (event.Key == termbox.MouseWheelDown || (event.Key == termbox.MouseLeft &&
-					event.MouseX == sx-1)) && tabbarscroll < len(global.Buffers)-1 {
-					tabbarscroll++
-				} else if event.Key == termbox.MouseLeft {
-					otbx := 0
-					tbx := 1
-					i := tabbarscroll
-					for _, buf := range global.Buffers[tabbarscroll:] {
-						tbx += buf.File.FilenameWidth + 3
-						if otbx < event.MouseX && event.MouseX < tbx {
-							global.SwitchBuf(i)
-							continue
-						}
-						i++
-						otbx = tbx
+			loop.sync()
+		} else if event.Ch == 0 {
+			switch event.Key {
+			case termbox.KeyCtrlC:
+				loop.done = global.ConfirmQuit(loop.tabbarscroll)
+				loop.sync()
+			case termbox.KeyCtrlX:
+				loop.ctlx = true
+			case termbox.KeyCtrlS, termbox.KeyCtrlR:
+				if event.Mod == termbox.ModAlt {
+					global.RegexSearch(loop.tabbarscroll, 0, bufareatop, loop.sx, bufareabot,
+						event.Key == termbox.KeyCtrlR, n)
+				} else {
+					global.Search(loop.tabbarscroll, 0, bufareatop, loop.sx, bufareabot,
+						event.Key == termbox.KeyCtrlR, n)
+				}
+				loop.sync()
+			case termbox.KeyCtrlF, termbox.KeyArrowRight:
+				if event.Mod == termbox.ModAlt {
+					global.FocusBuf().ForwardDWord(n)
+				} else {
+					global.FocusBuf().ForwardByte(n)
+				}
+			case termbox.KeyCtrlN, termbox.KeyArrowDown:
+				global.FocusBuf().ForwardParagraph(n)
+			case termbox.KeyCtrlB, termbox.KeyArrowLeft:
+				if event.Mod == termbox.ModAlt {
+					global.FocusBuf().BackwardDWord(n)
+				} else {
+					global.FocusBuf().BackwardByte(n)
+				}
+			case termbox.KeyCtrlP, termbox.KeyArrowUp:
+				global.FocusBuf().BackwardParagraph(n)
+			case termbox.KeyPgup:
+				global.PageUp(0, bufareatop, loop.sx, bufareabot, n)
+			case termbox.KeyCtrlV, termbox.KeyPgdn:
+				global.PageDown(0, bufareatop, loop.sx, bufareabot, n)
+			case termbox.KeyCtrlA, termbox.KeyHome:
+				global.FocusBuf().StartOfLine()
+			case termbox.KeyCtrlE, termbox.KeyEnd:
+				global.FocusBuf().EndOfLine()
+			case termbox.KeyF1:
+				helpscreen()
+				loop.sync()
+			case termbox.KeyEnter:
+				global.FocusBuf().Edit(global, loop.tabbarscroll, n)
+				loop.sync()
+			case termbox.KeyCtrlSpace:
+				global.FocusBuf().SetMark()
+			case termbox.KeyCtrlW:
+				global.CutRegion()
+			case termbox.KeyCtrlY:
+				repeat(n, func() bool { return global.Yank(loop.tabbarscroll) })
+				loop.sync()
+			case termbox.KeySpace:
+				if global.FocusBuf().Typing && global.FocusBuf().TextColumn {
+					repeat(n, func() bool { return global.FocusBuf().TypeText(' ') })
+				} else if !global.FocusBuf().Typing && global.FocusBuf().Mode == ModePattern {
+					global.FocusBuf().ToggleBit()
+				}
+			case termbox.KeyTab:
+				global.FocusBuf().SwitchColumn()
+			case termbox.KeyCtrlT:
+				global.FocusBuf().ToggleTyping()
+			case termbox.KeyCtrlG:
+				global.FocusBuf().MarkActive = false
+				global.FocusBuf().Highlight = nil
+			case termbox.KeyInsert:
+				global.FocusBuf().Insert = !global.FocusBuf().Insert
+			case termbox.KeyCtrlD, termbox.KeyDelete:
+				global.FocusBuf().DeleteForward(n)
+			case termbox.KeyBackspace, termbox.KeyBackspace2:
+				global.FocusBuf().DeleteBackward(n)
+			case termbox.KeyCtrlUnderscore:
+				if event.Mod == termbox.ModAlt {
+					if len(global.FocusBuf().RedoList) == 0 {
+						global.Msg("Nothing to redo")
+					}
+					repeat(n, global.FocusBuf().Redo)
+				} else {
+					if len(global.FocusBuf().UndoList) == 0 {
+						global.Msg("No further undo information")
 					}
+					repeat(n, global.FocusBuf().Undo)
+				}
+			}
+		} else if event.Mod == termbox.ModAlt {
+			switch event.Ch {
+			case '<':
+				global.FocusBuf().StartOfFile()
+			case '>':
+				global.FocusBuf().EndOfFile()
+			case 'v':
+				global.PageUp(0, bufareatop, loop.sx, bufareabot, n)
+			case 'w':
+				global.CopyRegion()
+			case 'y':
+				global.YankPop(loop.tabbarscroll)
+				loop.sync()
+			case 'f':
+				global.FocusBuf().ForwardWord(n)
+			case 'b':
+				global.FocusBuf().BackwardWord(n)
+			case 'g':
+				global.FocusBuf().GoTo(global, loop.tabbarscroll)
+				loop.sync()
+			case 's':
+				loop.StartISearch()
+			case 'n':
+				global.ValueSearch(loop.tabbarscroll, 0, bufareatop, loop.sx, bufareabot, n)
+				loop.sync()
+			case '-', '_':
+				repeat(clampCount(n, ZSplitMax), always(global.VSplit))
+			case '|':
+				repeat(clampCount(n, ZSplitMax), always(global.HSplit))
+			case 'u', 'U':
+				repeat(n, global.SplitUp)
+			case 'd', 'D':
+				repeat(n, global.SplitDown)
+			case 'l', 'L':
+				repeat(n, global.SplitLeft)
+			case 'r', 'R':
+				repeat(n, global.SplitRight)
+			}
+		} else if global.FocusBuf().Typing && global.FocusBuf().TextColumn {
+			repeat(n, func() bool { return global.FocusBuf().TypeText(event.Ch) })
+		} else if global.FocusBuf().Typing {
+			repeat(n, func() bool { return global.FocusBuf().TypeHex(event.Ch) })
+		} else {
+			switch event.Ch {
+			case 'H':
+				if Int8 < global.FocusBuf().IntWidth {
+					global.FocusBuf().IntWidth--
+				}
+			case 'L':
+				if global.FocusBuf().IntWidth < Int64 {
+					global.FocusBuf().IntWidth++
+				}
+			case 'A':
+				// alt+arrows give ABCD on my term, and zerz is modal, so why not :)
+				repeat(n, global.SplitUp)
+			case 'B':
+				repeat(n, global.SplitDown)
+			case 'D':
+				repeat(n, global.SplitLeft)
+			case 'C':
+				repeat(n, global.SplitRight)
+			case 'h':
+				if global.FocusBuf().Mode == ModePattern {
+					global.FocusBuf().BackwardBit(n)
 				}
-			} else if 1 < event.MouseY && event.MouseY <= bufareabot {
-				if event.Key == termbox.MouseWheelDown {
-					global.FocusBuf().ScrollDown()
-				} else if event.Key == termbox.MouseWheelUp {
-					global.ScrollUp(0, bufareatop, sx, bufareabot)
-				} else if event.Key == termbox.MouseLeft {
-					global.Click(0, bufareatop, sx, bufareabot, event.MouseX, event.MouseY)
+			case 'l':
+				if global.FocusBuf().Mode == ModePattern {
+					global.FocusBuf().ForwardBit(n)
 				}
+			case '0', '1':
+				if global.FocusBuf().Mode == ModePattern {
+					global.FocusBuf().SetBit(event.Ch == '1')
+				}
+			case 'c':
+				global.FocusBuf().Mode = ModeChar
+			case 'p', 'P':
+				global.FocusBuf().Mode = ModePattern
+			case 'i', 'I':
+				global.FocusBuf().Mode = ModeInt
+			case 'u', 'U':
+				global.FocusBuf().Mode = ModeUInt
+			case 'e', 'E':
+				global.FocusBuf().BigEndian = !global.FocusBuf().BigEndian
+			case '?':
+				helpscreen()
+				loop.sync()
+			case 'q', 'Q':
+				loop.done = global.ConfirmQuit(loop.tabbarscroll)
+				loop.sync()
+			}
+		}
+		global.DoScroll(0, bufareatop, loop.sx, bufareabot)
+	} else if event.Type == termbox.EventMouse {
+		if event.MouseY < 1 {
+			if (event.Key == termbox.MouseWheelUp || (event.Key == termbox.MouseLeft &&
+				event.MouseX == 0)) && loop.tabbarscroll > 0 {
+				loop.tabbarscroll--
+			} else if (event.Key == termbox.MouseWheelDown || (event.Key == termbox.MouseLeft &&
+				event.MouseX == loop.sx-1)) && loop.tabbarscroll < len(global.Buffers)-1 {
+				loop.tabbarscroll++
+			} else if event.Key == termbox.MouseLeft {
+				otbx := 0
+				tbx := 1
+				i := loop.tabbarscroll
+				for _, buf := range global.Buffers[loop.tabbarscroll:] {
+					tbx += buf.File.FilenameWidth + 3
+					if otbx < event.MouseX && event.MouseX < tbx {
+						global.SwitchBuf(i)
+						continue
+					}
+					i++
+					otbx = tbx
+				}
+			}
+		} else if 1 < event.MouseY && event.MouseY <= bufareabot {
+			if event.Key == termbox.MouseWheelDown {
+				global.FocusBuf().ScrollDown()
+			} else if event.Key == termbox.MouseWheelUp {
+				global.ScrollUp(0, bufareatop, loop.sx, bufareabot)
+			} else if event.Key == termbox.MouseLeft {
+				global.Click(0, bufareatop, loop.sx, bufareabot, event.MouseX, event.MouseY,
+					event.Mod&termbox.ModMotion != 0)
 			}
 		}
 	}
 }
+
+func zerz(global *ZerzEditor, startupErrors []error) {
+	initTerm()
+	defer termbox.Close()
+	defer global.recoverPanic()
+	if len(startupErrors) > 0 {
+		showErrorList("Zerz", "Some files had errors:", startupErrors)
+		for _, err := range startupErrors {
+			global.Error(err)
+		}
+		global.Echo = ""
+	}
+	global.RecoverJournals()
+	loop := &zerzLoop{global: global}
+	loop.sx, loop.sy = termbox.Size()
+	for !loop.done {
+		loop.draw()
+		event := termbox.PollEvent()
+		if event.Type == termbox.EventKey || event.Type == termbox.EventMouse {
+			// Messages last until the next thing the user does
+			global.Echo = ""
+		}
+		global.RecordEvent(event)
+		loop.handleEvent(event)
+		global.reportJournalErrors()
+	}
+	global.DiscardJournals()
+}